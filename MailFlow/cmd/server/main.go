@@ -12,11 +12,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/mailflow/smtp-loadbalancer/internal/api"
+	"github.com/mailflow/smtp-loadbalancer/internal/auth"
+	"github.com/mailflow/smtp-loadbalancer/internal/bounce"
 	"github.com/mailflow/smtp-loadbalancer/internal/config"
 	"github.com/mailflow/smtp-loadbalancer/internal/database"
 	"github.com/mailflow/smtp-loadbalancer/internal/queue"
 	smtphealth "github.com/mailflow/smtp-loadbalancer/internal/smtp"
 	"github.com/mailflow/smtp-loadbalancer/internal/stats"
+	"github.com/mailflow/smtp-loadbalancer/internal/unsubscribe"
+	"github.com/mailflow/smtp-loadbalancer/internal/webhook"
 	"github.com/mailflow/smtp-loadbalancer/internal/worker"
 )
 
@@ -34,11 +38,19 @@ func main() {
 	}
 	defer database.Close()
 
+	if err := database.InitDefaultAdmin(cfg.Admin.Username, cfg.Admin.Password); err != nil {
+		log.Fatalf("初始化管理员账号失败: %v", err)
+	}
+
 	if err := queue.Connect(&cfg.Redis); err != nil {
 		log.Fatalf("Redis连接失败: %v", err)
 	}
 	defer queue.Close()
 
+	if err := api.InitSessionStore(&cfg.Session); err != nil {
+		log.Fatalf("初始化会话存储失败: %v", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -48,12 +60,32 @@ func main() {
 	go smtphealth.StartHealthCheck(ctx)
 	log.Println("SMTP健康检查模块已启动")
 
-	worker.Start(ctx, cfg.Worker.Count)
+	go queue.StartReaper(ctx)
+	log.Println("队列回收模块已启动")
+
+	go queue.StartScheduler(ctx)
+	log.Println("定时发送模块已启动")
+
+	go auth.StartExpirySweeper(ctx)
+	log.Println("API Key过期检查模块已启动")
+
+	webhook.Start(ctx, cfg.Server.WebhookAllowPrivate)
+	log.Println("Webhook分发模块已启动")
+
+	bounce.Start(ctx)
+	log.Println("退信处理模块已启动")
+
+	unsubscribe.Configure(&cfg.Unsubscribe)
+
+	worker.Start(ctx, &cfg.Worker)
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("可信代理配置无效: %v", err)
+	}
 	
 	api.RegisterPublicAPI(r)
-	api.RegisterAPIKeyAPI(r)
+	api.RegisterAPIKeyAPI(r, cfg)
 	api.RegisterAdminAPI(r, cfg)
 	api.RegisterWebUI(r, cfg)
 
@@ -179,9 +179,32 @@ configure_app() {
         log_info "已复制web目录"
     fi
     
+    SESSION_SECRET=$(grep -A1 '^  secrets:' "$INSTALL_DIR/config.yaml" 2>/dev/null | sed -n 's/^ *- *//p')
+    if [ -z "$SESSION_SECRET" ]; then
+        SESSION_SECRET=$(openssl rand -hex 32)
+        log_info "已生成会话密钥"
+    fi
+    
+    UNSUBSCRIBE_SECRET=$(grep -A2 '^unsubscribe:' "$INSTALL_DIR/config.yaml" 2>/dev/null | sed -n 's/^  secret: *//p' | tr -d '"')
+    if [ -z "$UNSUBSCRIBE_SECRET" ]; then
+        UNSUBSCRIBE_SECRET=$(openssl rand -hex 32)
+        log_info "已生成退订链接密钥"
+    fi
+    UNSUBSCRIBE_BASE_URL=$(grep -A2 '^unsubscribe:' "$INSTALL_DIR/config.yaml" 2>/dev/null | sed -n 's/^  base_url: *//p' | tr -d '"')
+    if [ -n "$UNSUBSCRIBE_BASE_URL" ] && [ "${UNSUBSCRIBE_BASE_URL#https://}" = "$UNSUBSCRIBE_BASE_URL" ]; then
+        log_warn "退订地址必须使用https，已清空unsubscribe.base_url: $UNSUBSCRIBE_BASE_URL"
+        UNSUBSCRIBE_BASE_URL=""
+    fi
+    
     cat > "$INSTALL_DIR/config.yaml" << EOF
 server:
   port: $SERVER_PORT
+  max_attachment_size: 10
+  max_batch_size: 500
+  key_rotation_grace: 24h
+  trusted_proxies:
+    - 127.0.0.1
+  webhook_allow_private: false
 
 database:
   host: localhost
@@ -198,10 +221,26 @@ redis:
 
 worker:
   count: $WORKER_COUNT
+  max_attempts: 5
+  max_age: 24h
+  retry_base_delay: 30s
+  retry_max_delay: 1h
 
 admin:
   username: $ADMIN_USER
   password: $ADMIN_PASSWORD
+
+session:
+  secrets:
+    - $SESSION_SECRET
+  store: cookie
+  secure: false
+  same_site: lax
+  max_age: 168h
+
+unsubscribe:
+  base_url: "$UNSUBSCRIBE_BASE_URL"
+  secret: "$UNSUBSCRIBE_SECRET"
 EOF
     
     log_info "配置文件已生成: $INSTALL_DIR/config.yaml"
@@ -283,6 +322,12 @@ show_info() {
     echo "API地址:"
     echo "  http://$SERVER_IP:$SERVER_PORT/api/v1/send"
     echo ""
+    if [ -z "$UNSUBSCRIBE_BASE_URL" ]; then
+        echo "营销邮件:"
+        echo "  未配置退订地址，营销邮件不可发送"
+        echo "  如需启用，请在 $INSTALL_DIR/config.yaml 中将 unsubscribe.base_url 设为指向本服务的https地址"
+        echo ""
+    fi
     echo "======================================"
     echo ""
 }
@@ -478,6 +523,11 @@ update_app() {
         log_info "已恢复配置文件"
     fi
     
+    if grep -A2 '^unsubscribe:' "$INSTALL_DIR/config.yaml" 2>/dev/null | grep -q '^  base_url: *"\?http://'; then
+        sed -i '/^unsubscribe:/,/^[^ ]/ s|^  base_url: .*|  base_url: ""|' "$INSTALL_DIR/config.yaml"
+        log_warn "退订地址必须使用https，已清空unsubscribe.base_url，配置https地址前营销邮件不可发送"
+    fi
+    
     log_info "启动服务..."
     systemctl start mailflow
     sleep 2
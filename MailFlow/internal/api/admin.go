@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -10,8 +11,10 @@ import (
 	"github.com/mailflow/smtp-loadbalancer/internal/auth"
 	"github.com/mailflow/smtp-loadbalancer/internal/config"
 	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/keys"
 	"github.com/mailflow/smtp-loadbalancer/internal/models"
 	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/mailflow/smtp-loadbalancer/internal/ratelimit"
 	smtphealth "github.com/mailflow/smtp-loadbalancer/internal/smtp"
 	"github.com/mailflow/smtp-loadbalancer/internal/stats"
 )
@@ -20,48 +23,105 @@ func RegisterAdminAPI(r *gin.Engine, cfg *config.Config) {
 	admin := r.Group("/admin/api")
 	admin.Use(AdminAuthMiddleware(cfg))
 	{
-		admin.GET("/plans", listPlans)
-		admin.POST("/plans", createPlan)
-		admin.PUT("/plans/:id", updatePlan)
-		admin.DELETE("/plans/:id", deletePlan)
-		admin.PUT("/plans/:id/toggle", togglePlanStatus)
+		admin.GET("/plans", requirePermission(permPlansRead), listPlans)
+		admin.POST("/plans", requirePermission(permPlansWrite), createPlan)
+		admin.PUT("/plans/:id", requirePermission(permPlansWrite), updatePlan)
+		admin.DELETE("/plans/:id", requirePermission(permPlansWrite), deletePlan)
+		admin.PUT("/plans/:id/toggle", requirePermission(permPlansWrite), togglePlanStatus)
 		
-		admin.GET("/keys", listAPIKeys)
-		admin.POST("/keys", createAPIKey)
-		admin.PUT("/keys/:id", updateAPIKey)
-		admin.DELETE("/keys/:id", deleteAPIKey)
-		admin.GET("/keys/:id/quota", getAPIKeyQuota)
-		admin.POST("/keys/:id/reset-quota", resetAPIKeyQuota)
-		admin.POST("/keys/:id/adjust-quota", adjustAPIKeyQuota)
-		admin.POST("/keys/batch-delete", batchDeleteAPIKeys)
-		admin.POST("/keys/batch-status", batchUpdateAPIKeysStatus)
-
-		admin.GET("/smtp-configs", listSMTPConfigs)
-		admin.POST("/smtp-configs", createSMTPConfig)
-		admin.POST("/smtp-configs/batch-import", batchImportSMTPConfigs)
-		admin.PUT("/smtp-configs/:id", updateSMTPConfig)
-		admin.DELETE("/smtp-configs/:id", deleteSMTPConfig)
-		admin.POST("/smtp-configs/:id/test", testSMTPConfig)
-		admin.POST("/smtp-configs/:id/pause", pauseSMTPConfig)
-		admin.POST("/smtp-configs/:id/resume", resumeSMTPConfig)
-		admin.POST("/smtp-configs/:id/reset-quota", resetSMTPQuota)
-		admin.GET("/smtp-configs/:id/health", getSMTPHealth)
-		admin.POST("/smtp-configs/batch-test", batchTestSMTPConfigs)
-		admin.POST("/smtp-configs/batch-delete", batchDeleteSMTPConfigs)
-		admin.POST("/smtp-configs/batch-status", batchUpdateSMTPConfigsStatus)
-
-		admin.GET("/stats", getStats)
-		admin.GET("/stats/period", getPeriodStats)
-		admin.GET("/key-stats", getKeyStats)
-		admin.GET("/key-stats-detail", getKeyStatsDetail)
-		admin.GET("/smtp-stats", getSMTPStats)
-		admin.GET("/trend", getTrend)
-		admin.GET("/logs", getLogs)
+		admin.GET("/keys", requirePermission(permKeysRead), listAPIKeys)
+		admin.POST("/keys", requirePermission(permKeysWrite), createAPIKey)
+		admin.POST("/keys/lookup", requirePermission(permKeysRead), lookupAPIKey)
+		admin.POST("/keys/:id/rotate", requirePermission(permKeysWrite), rotateAPIKey)
+		admin.PUT("/keys/:id", requirePermission(permKeysWrite), updateAPIKey)
+		admin.DELETE("/keys/:id", requirePermission(permKeysWrite), deleteAPIKey)
+		admin.GET("/keys/:id/quota", requirePermission(permKeysRead), getAPIKeyQuota)
+		admin.POST("/keys/:id/reset-quota", requirePermission(permKeysWrite), resetAPIKeyQuota)
+		admin.POST("/keys/:id/adjust-quota", requirePermission(permKeysWrite), adjustAPIKeyQuota)
+		admin.POST("/keys/batch-delete", requirePermission(permKeysWrite), batchDeleteAPIKeys)
+		admin.POST("/keys/batch-status", requirePermission(permKeysWrite), batchUpdateAPIKeysStatus)
+		admin.GET("/keys/:id/identities", requirePermission(permKeysRead), listIdentities)
+		admin.POST("/keys/:id/identities", requirePermission(permKeysWrite), createIdentity)
+		admin.PUT("/identities/:id", requirePermission(permKeysWrite), updateIdentity)
+		admin.DELETE("/identities/:id", requirePermission(permKeysWrite), deleteIdentity)
+
+		admin.GET("/smtp-configs", requirePermission(permSMTPRead), listSMTPConfigs)
+		admin.POST("/smtp-configs", requirePermission(permSMTPWrite), createSMTPConfig)
+		admin.POST("/smtp-configs/batch-import", requirePermission(permSMTPWrite), batchImportSMTPConfigs)
+		admin.PUT("/smtp-configs/:id", requirePermission(permSMTPWrite), updateSMTPConfig)
+		admin.DELETE("/smtp-configs/:id", requirePermission(permSMTPWrite), deleteSMTPConfig)
+		admin.POST("/smtp-configs/:id/test", requirePermission(permSMTPWrite), testSMTPConfig)
+		admin.POST("/smtp-configs/:id/test-bounce", requirePermission(permSMTPWrite), testBounceMailbox)
+		admin.POST("/smtp-configs/:id/pause", requirePermission(permSMTPWrite), pauseSMTPConfig)
+		admin.POST("/smtp-configs/:id/resume", requirePermission(permSMTPWrite), resumeSMTPConfig)
+		admin.POST("/smtp-configs/:id/reset-quota", requirePermission(permSMTPWrite), resetSMTPQuota)
+		admin.GET("/smtp-configs/:id/health", requirePermission(permSMTPRead), getSMTPHealth)
+		admin.POST("/smtp-configs/batch-test", requirePermission(permSMTPWrite), batchTestSMTPConfigs)
+		admin.POST("/smtp-configs/batch-delete", requirePermission(permSMTPWrite), batchDeleteSMTPConfigs)
+		admin.POST("/smtp-configs/batch-status", requirePermission(permSMTPWrite), batchUpdateSMTPConfigsStatus)
+
+		admin.GET("/dkim-keys", requirePermission(permSMTPRead), listDKIMKeys)
+		admin.POST("/dkim-keys", requirePermission(permSMTPWrite), createDKIMKey)
+		admin.PUT("/dkim-keys/:id/toggle", requirePermission(permSMTPWrite), toggleDKIMKey)
+		admin.DELETE("/dkim-keys/:id", requirePermission(permSMTPWrite), deleteDKIMKey)
+
+		admin.GET("/stats", requirePermission(permStatsRead), getStats)
+		admin.GET("/stats/period", requirePermission(permStatsRead), getPeriodStats)
+		admin.GET("/key-stats", requirePermission(permStatsRead), getKeyStats)
+		admin.GET("/key-stats-detail", requirePermission(permStatsRead), getKeyStatsDetail)
+		admin.GET("/smtp-stats", requirePermission(permStatsRead), getSMTPStats)
+		admin.GET("/trend", requirePermission(permStatsRead), getTrend)
+		admin.GET("/logs", requirePermission(permLogsRead), getLogs)
+
+		admin.GET("/dead-letters", requirePermission(permDeadLettersRead), listDeadLetters)
+		admin.GET("/dead-letters/:id", requirePermission(permDeadLettersRead), getDeadLetter)
+		admin.POST("/dead-letters/:id/replay", requirePermission(permDeadLettersWrite), replayDeadLetter)
+		admin.DELETE("/dead-letters/:id", requirePermission(permDeadLettersWrite), deleteDeadLetter)
+		admin.POST("/dead-letters/batch-replay", requirePermission(permDeadLettersWrite), batchReplayDeadLetters)
+		admin.POST("/dead-letters/batch-delete", requirePermission(permDeadLettersWrite), batchDeleteDeadLetters)
+		admin.POST("/dead-letters/purge", requirePermission(permDeadLettersWrite), purgeDeadLetters)
+
+		admin.GET("/events", requirePermission(permEventsRead), listEvents)
+
+		admin.GET("/templates", requirePermission(permTemplatesRead), listTemplates)
+		admin.GET("/templates/:id", requirePermission(permTemplatesRead), getTemplate)
+		admin.POST("/templates", requirePermission(permTemplatesWrite), createTemplate)
+		admin.PUT("/templates/:id", requirePermission(permTemplatesWrite), updateTemplate)
+		admin.DELETE("/templates/:id", requirePermission(permTemplatesWrite), deleteTemplate)
 		
-		admin.GET("/admin-tokens", listAdminTokens)
-		admin.POST("/admin-tokens", createAdminToken)
-		admin.DELETE("/admin-tokens/:id", deleteAdminToken)
-		admin.PUT("/admin-tokens/:id/toggle", toggleAdminToken)
+		admin.GET("/webhooks", requirePermission(permWebhooksRead), listWebhooks)
+		admin.POST("/webhooks", requirePermission(permWebhooksWrite), createWebhook)
+		admin.PUT("/webhooks/:id", requirePermission(permWebhooksWrite), updateWebhook)
+		admin.PUT("/webhooks/:id/toggle", requirePermission(permWebhooksWrite), toggleWebhook)
+		admin.DELETE("/webhooks/:id", requirePermission(permWebhooksWrite), deleteWebhook)
+		admin.GET("/webhook-deliveries", requirePermission(permWebhooksRead), listWebhookDeliveries)
+		admin.GET("/webhook-deliveries/:id", requirePermission(permWebhooksRead), getWebhookDelivery)
+		admin.POST("/webhook-deliveries/:id/retry", requirePermission(permWebhooksWrite), retryWebhookDelivery)
+
+		admin.GET("/suppressions", requirePermission(permSuppressionsRead), listSuppressions)
+		admin.POST("/suppressions", requirePermission(permSuppressionsWrite), createSuppression)
+		admin.POST("/suppressions/import", requirePermission(permSuppressionsWrite), importSuppressions)
+		admin.DELETE("/suppressions/:id", requirePermission(permSuppressionsWrite), deleteSuppression)
+		
+		admin.GET("/admin-tokens", requirePermission(permAdminManage), listAdminTokens)
+		admin.POST("/admin-tokens", requirePermission(permAdminManage), createAdminToken)
+		admin.DELETE("/admin-tokens/:id", requirePermission(permAdminManage), deleteAdminToken)
+		admin.PUT("/admin-tokens/:id/toggle", requirePermission(permAdminManage), toggleAdminToken)
+
+		admin.GET("/users", requirePermission(permAdminManage), listAdminUsers)
+		admin.POST("/users", requirePermission(permAdminManage), createAdminUser)
+		admin.PUT("/users/:id", requirePermission(permAdminManage), updateAdminUser)
+		admin.DELETE("/users/:id", requirePermission(permAdminManage), deleteAdminUser)
+		admin.DELETE("/users/:id/totp", requirePermission(permAdminManage), resetAdminUserTOTP)
+		admin.POST("/users/:id/sessions/revoke", requirePermission(permAdminManage), revokeAdminUserSessions)
+
+		admin.GET("/me", getCurrentAdmin)
+		admin.PUT("/me/password", changeMyPassword)
+		admin.POST("/me/sessions/revoke", revokeMySessions)
+		admin.POST("/me/totp/setup", setupMyTOTP)
+		admin.POST("/me/totp/enable", enableMyTOTP)
+		admin.POST("/me/totp/disable", disableMyTOTP)
+		admin.POST("/me/totp/recovery-codes", regenerateMyRecoveryCodes)
 	}
 }
 
@@ -81,6 +141,11 @@ func createPlan(c *gin.Context) {
 		return
 	}
 
+	if !ratelimit.Valid(plan.RateLimitAlgorithm) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的限流算法"})
+		return
+	}
+
 	var existing models.Plan
 	if err := database.DB.Where("code = ?", plan.Code).First(&existing).Error; err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "套餐代码已存在"})
@@ -114,6 +179,11 @@ func updatePlan(c *gin.Context) {
 		return
 	}
 
+	if !ratelimit.Valid(req.RateLimitAlgorithm) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的限流算法"})
+		return
+	}
+
 	if req.Code != plan.Code {
 		var existing models.Plan
 		if err := database.DB.Where("code = ? AND id != ?", req.Code, id).First(&existing).Error; err == nil {
@@ -129,6 +199,9 @@ func updatePlan(c *gin.Context) {
 	plan.DailyLimit = req.DailyLimit
 	plan.WeeklyLimit = req.WeeklyLimit
 	plan.MonthlyLimit = req.MonthlyLimit
+	plan.MaxAttachmentSize = req.MaxAttachmentSize
+	plan.RateLimitAlgorithm = req.RateLimitAlgorithm
+	plan.BurstLimit = req.BurstLimit
 	plan.IsActive = req.IsActive
 	plan.SortOrder = req.SortOrder
 
@@ -189,23 +262,33 @@ func togglePlanStatus(c *gin.Context) {
 }
 
 func listAPIKeys(c *gin.Context) {
-	var keys []models.APIKey
-	if err := database.DB.Order("created_at DESC").Find(&keys).Error; err != nil {
+	var apiKeys []models.APIKey
+	if err := database.DB.Order("created_at DESC").Find(&apiKeys).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
 		return
 	}
-	c.JSON(http.StatusOK, keys)
+	c.JSON(http.StatusOK, apiKeys)
+}
+
+type RevealedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
 }
 
 func createAPIKey(c *gin.Context) {
 	var req struct {
-		Name         string `json:"name" binding:"required"`
-		PlanID       *uint  `json:"plan_id"`
-		MinuteLimit  *int   `json:"minute_limit"`
-		DailyLimit   *int   `json:"daily_limit"`
-		WeeklyLimit  *int   `json:"weekly_limit"`
-		MonthlyLimit *int   `json:"monthly_limit"`
-		TotalLimit   int    `json:"total_limit"`
+		Name           string `json:"name" binding:"required"`
+		PlanID         *uint  `json:"plan_id"`
+		MinuteLimit    *int   `json:"minute_limit"`
+		DailyLimit     *int   `json:"daily_limit"`
+		WeeklyLimit    *int   `json:"weekly_limit"`
+		MonthlyLimit   *int   `json:"monthly_limit"`
+		TotalLimit     int    `json:"total_limit"`
+		StartsAt       string `json:"starts_at"`
+		ExpiresAt      string `json:"expires_at"`
+		AllowedIPs     string `json:"allowed_ips"`
+		AllowedOrigins string `json:"allowed_origins"`
+		Scopes         string `json:"scopes"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -213,12 +296,47 @@ func createAPIKey(c *gin.Context) {
 		return
 	}
 
+	startsAt, expiresAt, err := parseValidity(&req.StartsAt, &req.ExpiresAt, nil, nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	allowedIPs, err := auth.NormalizeIPAllowlist(req.AllowedIPs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	allowedOrigins, err := auth.NormalizeOrigins(req.AllowedOrigins)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	scopes, err := auth.NormalizeScopes(req.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := keys.Generate()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成API Key失败"})
+		return
+	}
+
 	key := models.APIKey{
-		Key:        uuid.New().String(),
-		Name:       req.Name,
-		TotalLimit: req.TotalLimit,
-		TotalUsed:  0,
-		Status:     "active",
+		KeyHash:        secret.Hash,
+		KeyPrefix:      secret.Prefix,
+		KeyLast4:       secret.Last4,
+		Name:           req.Name,
+		TotalLimit:     req.TotalLimit,
+		TotalUsed:      0,
+		Status:         "active",
+		StartsAt:       startsAt,
+		ExpiresAt:      expiresAt,
+		AllowedIPs:     allowedIPs,
+		AllowedOrigins: allowedOrigins,
+		Scopes:         scopes,
 	}
 
 	if req.PlanID != nil {
@@ -235,6 +353,8 @@ func createAPIKey(c *gin.Context) {
 		key.DailyLimit = plan.DailyLimit
 		key.WeeklyLimit = plan.WeeklyLimit
 		key.MonthlyLimit = plan.MonthlyLimit
+		key.RateLimitAlgorithm = plan.RateLimitAlgorithm
+		key.BurstLimit = plan.BurstLimit
 	} else {
 		if req.MinuteLimit == nil || req.DailyLimit == nil || req.WeeklyLimit == nil || req.MonthlyLimit == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "自定义配置需要提供所有限额参数"})
@@ -255,6 +375,24 @@ func createAPIKey(c *gin.Context) {
 		return
 	}
 
+	c.JSON(http.StatusOK, RevealedAPIKey{key, secret.Plain})
+}
+
+func lookupAPIKey(c *gin.Context) {
+	var req struct {
+		Key string `json:"key" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	var key models.APIKey
+	if err := database.DB.Where("key_hash = ?", keys.Hash(req.Key)).First(&key).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API Key不存在"})
+		return
+	}
+
 	c.JSON(http.StatusOK, key)
 }
 
@@ -268,15 +406,20 @@ func updateAPIKey(c *gin.Context) {
 	}
 
 	var req struct {
-		Name         *string `json:"name"`
-		PlanID       *uint   `json:"plan_id"`
-		IsCustom     *bool   `json:"is_custom"`
-		MinuteLimit  *int    `json:"minute_limit"`
-		DailyLimit   *int    `json:"daily_limit"`
-		WeeklyLimit  *int    `json:"weekly_limit"`
-		MonthlyLimit *int    `json:"monthly_limit"`
-		TotalLimit   *int    `json:"total_limit"`
-		Status       *string `json:"status"`
+		Name           *string `json:"name"`
+		PlanID         *uint   `json:"plan_id"`
+		IsCustom       *bool   `json:"is_custom"`
+		MinuteLimit    *int    `json:"minute_limit"`
+		DailyLimit     *int    `json:"daily_limit"`
+		WeeklyLimit    *int    `json:"weekly_limit"`
+		MonthlyLimit   *int    `json:"monthly_limit"`
+		TotalLimit     *int    `json:"total_limit"`
+		Status         *string `json:"status"`
+		StartsAt       *string `json:"starts_at"`
+		ExpiresAt      *string `json:"expires_at"`
+		AllowedIPs     *string `json:"allowed_ips"`
+		AllowedOrigins *string `json:"allowed_origins"`
+		Scopes         *string `json:"scopes"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -284,6 +427,45 @@ func updateAPIKey(c *gin.Context) {
 		return
 	}
 
+	if req.StartsAt != nil || req.ExpiresAt != nil {
+		startsAt, expiresAt, err := parseValidity(req.StartsAt, req.ExpiresAt, key.StartsAt, key.ExpiresAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.StartsAt = startsAt
+		key.ExpiresAt = expiresAt
+
+		if key.Status == "expired" && (expiresAt == nil || expiresAt.After(time.Now())) {
+			key.Status = "active"
+		}
+	}
+
+	if req.AllowedIPs != nil {
+		allowedIPs, err := auth.NormalizeIPAllowlist(*req.AllowedIPs)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.AllowedIPs = allowedIPs
+	}
+	if req.AllowedOrigins != nil {
+		allowedOrigins, err := auth.NormalizeOrigins(*req.AllowedOrigins)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.AllowedOrigins = allowedOrigins
+	}
+	if req.Scopes != nil {
+		scopes, err := auth.NormalizeScopes(*req.Scopes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.Scopes = scopes
+	}
+
 	if req.Name != nil {
 		key.Name = *req.Name
 	}
@@ -302,6 +484,8 @@ func updateAPIKey(c *gin.Context) {
 		key.DailyLimit = plan.DailyLimit
 		key.WeeklyLimit = plan.WeeklyLimit
 		key.MonthlyLimit = plan.MonthlyLimit
+		key.RateLimitAlgorithm = plan.RateLimitAlgorithm
+		key.BurstLimit = plan.BurstLimit
 	} else if req.IsCustom != nil && *req.IsCustom {
 		if req.MinuteLimit == nil || req.DailyLimit == nil || req.WeeklyLimit == nil || req.MonthlyLimit == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "自定义配置需要提供所有限额参数"})
@@ -342,18 +526,57 @@ func updateAPIKey(c *gin.Context) {
 		return
 	}
 
-	auth.InvalidateAPIKeyCache(c.Request.Context(), key.Key)
+	auth.InvalidateAPIKeyCache(c.Request.Context(), key.KeyHash, key.PreviousKeyHash)
 
 	c.JSON(http.StatusOK, key)
 }
 
+func parseValidity(startsAt, expiresAt *string, currentStartsAt, currentExpiresAt *time.Time) (*time.Time, *time.Time, error) {
+	start, err := parseOptionalTime("starts_at", startsAt, currentStartsAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	end, err := parseOptionalTime("expires_at", expiresAt, currentExpiresAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if start != nil && end != nil && !end.After(*start) {
+		return nil, nil, fmt.Errorf("expires_at必须晚于starts_at")
+	}
+	return start, end, nil
+}
+
+func parseOptionalTime(field string, value *string, current *time.Time) (*time.Time, error) {
+	if value == nil {
+		return current, nil
+	}
+	if *value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, fmt.Errorf("%s必须是RFC3339格式的时间", field)
+	}
+	return &t, nil
+}
+
 func deleteAPIKey(c *gin.Context) {
 	id := c.Param("id")
-	
-	if err := database.DB.Delete(&models.APIKey{}, id).Error; err != nil {
+
+	var key models.APIKey
+	if err := database.DB.First(&key, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API Key不存在"})
+		return
+	}
+
+	if err := database.DB.Delete(&key).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
 		return
 	}
+	database.DB.Where("api_key_id = ?", key.ID).Delete(&models.SenderIdentity{})
+	auth.InvalidateAPIKeyCache(c.Request.Context(), key.KeyHash, key.PreviousKeyHash)
 
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
@@ -364,6 +587,9 @@ func listSMTPConfigs(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
 		return
 	}
+	for i := range configs {
+		redactSMTPConfig(c, &configs[i])
+	}
 	c.JSON(http.StatusOK, configs)
 }
 
@@ -375,6 +601,11 @@ func createSMTPConfig(c *gin.Context) {
 		return
 	}
 
+	if err := normalizeBounceSettings(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if config.MaxPerHour == 0 {
 		config.MaxPerHour = 100
 	}
@@ -388,6 +619,7 @@ func createSMTPConfig(c *gin.Context) {
 		return
 	}
 
+	redactSMTPConfig(c, &config)
 	c.JSON(http.StatusOK, config)
 }
 
@@ -400,19 +632,39 @@ func updateSMTPConfig(c *gin.Context) {
 		return
 	}
 
+	password, bouncePassword := config.Password, config.BouncePassword
 	if err := c.ShouldBindJSON(&config); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
 		return
 	}
+	if config.Password == "" {
+		config.Password = password
+	}
+	if config.BouncePassword == "" {
+		config.BouncePassword = bouncePassword
+	}
+	if err := normalizeBounceSettings(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := database.DB.Save(&config).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
 		return
 	}
 
+	redactSMTPConfig(c, &config)
 	c.JSON(http.StatusOK, config)
 }
 
+func redactSMTPConfig(c *gin.Context, config *models.SMTPConfig) {
+	if c.GetString("admin_role") == RoleOwner {
+		return
+	}
+	config.Password = ""
+	config.BouncePassword = ""
+}
+
 func deleteSMTPConfig(c *gin.Context) {
 	id := c.Param("id")
 	
@@ -545,10 +797,18 @@ func batchDeleteAPIKeys(c *gin.Context) {
 		return
 	}
 
+	var apiKeys []models.APIKey
+	if err := database.DB.Where("id IN ?", req.IDs).Find(&apiKeys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
 	if err := database.DB.Delete(&models.APIKey{}, req.IDs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "批量删除失败"})
 		return
 	}
+	database.DB.Where("api_key_id IN ?", req.IDs).Delete(&models.SenderIdentity{})
+	invalidateKeyCaches(c, apiKeys)
 
 	c.JSON(http.StatusOK, gin.H{"message": "批量删除成功"})
 }
@@ -563,14 +823,29 @@ func batchUpdateAPIKeysStatus(c *gin.Context) {
 		return
 	}
 
+	var apiKeys []models.APIKey
+	if err := database.DB.Where("id IN ?", req.IDs).Find(&apiKeys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
 	if err := database.DB.Model(&models.APIKey{}).Where("id IN ?", req.IDs).Update("status", req.Status).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "批量更新失败"})
 		return
 	}
+	invalidateKeyCaches(c, apiKeys)
 
 	c.JSON(http.StatusOK, gin.H{"message": "批量更新成功"})
 }
 
+func invalidateKeyCaches(c *gin.Context, apiKeys []models.APIKey) {
+	hashes := make([]string, 0, len(apiKeys)*2)
+	for _, key := range apiKeys {
+		hashes = append(hashes, key.KeyHash, key.PreviousKeyHash)
+	}
+	auth.InvalidateAPIKeyCache(c.Request.Context(), hashes...)
+}
+
 func batchImportSMTPConfigs(c *gin.Context) {
 	var configs []struct {
 		Name       string `json:"name" binding:"required"`
@@ -748,7 +1023,7 @@ func adjustAPIKeyQuota(c *gin.Context) {
 		return
 	}
 
-	auth.InvalidateAPIKeyCache(c.Request.Context(), key.Key)
+	auth.InvalidateAPIKeyCache(c.Request.Context(), key.KeyHash, key.PreviousKeyHash)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "配额调整成功",
@@ -941,6 +1216,7 @@ func createAdminToken(c *gin.Context) {
 	var req struct {
 		Name        string `json:"name" binding:"required"`
 		Description string `json:"description"`
+		Role        string `json:"role"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -948,12 +1224,24 @@ func createAdminToken(c *gin.Context) {
 		return
 	}
 
+	if req.Role == "" {
+		req.Role = RoleReadOnly
+	}
+	if !validRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色"})
+		return
+	}
+
 	token := models.AdminToken{
 		Token:       uuid.New().String(),
 		Name:        req.Name,
 		Description: req.Description,
+		Role:        req.Role,
 		IsActive:    true,
 	}
+	if user := currentAdminUser(c); user != nil {
+		token.UserID = &user.ID
+	}
 
 	if err := database.DB.Create(&token).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
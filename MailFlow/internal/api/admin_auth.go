@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
@@ -9,31 +10,50 @@ import (
 	"github.com/mailflow/smtp-loadbalancer/internal/config"
 	"github.com/mailflow/smtp-loadbalancer/internal/database"
 	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/mailflow/smtp-loadbalancer/internal/sessionstore"
+	"golang.org/x/crypto/bcrypt"
 )
 
-var store = sessions.NewCookieStore([]byte("mailflow-secret-key-change-in-production"))
+const (
+	totpLoginWindow = 5 * time.Minute
+	maxTOTPAttempts = 5
+)
+
+const sessionName = "mailflow-session"
 
-func init() {
-	store.Options = &sessions.Options{
-		Path:     "/",
-		MaxAge:   86400 * 7,
-		HttpOnly: true,
+var store sessions.Store
+
+func InitSessionStore(cfg *config.SessionConfig) error {
+	s, err := sessionstore.NewStore(cfg)
+	if err != nil {
+		return err
 	}
+	store = s
+	return nil
 }
 
 func AdminAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if token := c.GetHeader("X-Admin-Token"); token != "" {
-			if validateAdminToken(token) {
+			if adminToken, user, ok := validateAdminToken(token); ok {
+				c.Set("admin_user", user)
+				c.Set("admin_role", adminToken.Role)
 				c.Next()
 				return
 			}
 		}
 		
-		session, _ := store.Get(c.Request, "mailflow-session")
-		if auth, ok := session.Values["authenticated"].(bool); ok && auth {
-			c.Next()
-			return
+		session, _ := store.Get(c.Request, sessionName)
+		if userID, ok := session.Values["user_id"].(uint); ok {
+			version, _ := session.Values["session_version"].(int)
+			var user models.AdminUser
+			if err := database.DB.First(&user, userID).Error; err == nil && user.IsActive && user.SessionVersion == version {
+				c.Set("admin_user", &user)
+				c.Set("admin_role", user.Role)
+				c.Next()
+				return
+			}
 		}
 		
 		if c.ContentType() == "application/json" || c.GetHeader("Accept") == "application/json" {
@@ -45,20 +65,37 @@ func AdminAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
-func validateAdminToken(token string) bool {
+func validateAdminToken(token string) (*models.AdminToken, *models.AdminUser, bool) {
 	var adminToken models.AdminToken
 	if err := database.DB.Where("token = ? AND is_active = ?", token, true).First(&adminToken).Error; err != nil {
-		return false
+		return nil, nil, false
+	}
+
+	var user *models.AdminUser
+	if adminToken.UserID != nil {
+		user = &models.AdminUser{}
+		if err := database.DB.First(user, *adminToken.UserID).Error; err != nil {
+			return nil, nil, false
+		}
+		if !user.IsActive || !roleAllows(user.Role, permAdminManage) {
+			return nil, nil, false
+		}
 	}
 
 	now := time.Now()
 	adminToken.LastUsedAt = &now
-	database.DB.Save(&adminToken)
+	database.DB.Model(&adminToken).Update("last_used_at", now)
 
-	return true
+	return &adminToken, user, true
 }
 
-func HandleLogin(cfg *config.Config) gin.HandlerFunc {
+func currentAdminUser(c *gin.Context) *models.AdminUser {
+	value, _ := c.Get("admin_user")
+	user, _ := value.(*models.AdminUser)
+	return user
+}
+
+func HandleLogin() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			Username string `json:"username"`
@@ -70,22 +107,104 @@ func HandleLogin(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 		
-		if req.Username == cfg.Admin.Username && req.Password == cfg.Admin.Password {
-			session, _ := store.Get(c.Request, "mailflow-session")
-			session.Values["authenticated"] = true
-			session.Save(c.Request, c.Writer)
-			
-			c.JSON(http.StatusOK, gin.H{"message": "登录成功"})
-		} else {
+		var user models.AdminUser
+		if err := database.DB.Where("username = ?", req.Username).First(&user).Error; err != nil || !checkPassword(&user, req.Password) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+			return
+		}
+		if !user.IsActive {
+			c.JSON(http.StatusForbidden, gin.H{"error": "账号已被禁用"})
+			return
+		}
+
+		session, _ := store.Get(c.Request, sessionName)
+		if user.TOTPEnabled {
+			delete(session.Values, "user_id")
+			session.Values["pending_user_id"] = user.ID
+			session.Values["pending_at"] = time.Now().Unix()
+			session.Save(c.Request, c.Writer)
+
+			c.JSON(http.StatusOK, gin.H{"message": "请输入两步验证码", "totp_required": true})
+			return
+		}
+
+		completeLogin(c, session, &user)
+	}
+}
+
+func HandleLoginTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Code string `json:"code" binding:"required"`
 		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求"})
+			return
+		}
+
+		session, _ := store.Get(c.Request, sessionName)
+		userID, ok := session.Values["pending_user_id"].(uint)
+		pendingAt, _ := session.Values["pending_at"].(int64)
+		if !ok || time.Since(time.Unix(pendingAt, 0)) > totpLoginWindow {
+			clearPendingLogin(c, session)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已过期，请重新输入密码"})
+			return
+		}
+
+		var user models.AdminUser
+		if err := database.DB.First(&user, userID).Error; err != nil || !user.IsActive || !user.TOTPEnabled {
+			clearPendingLogin(c, session)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已失效，请重新输入密码"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		attemptsKey := fmt.Sprintf("mailflow:totp:attempts:%d", user.ID)
+		if attempts, _ := queue.Client.Get(ctx, attemptsKey).Int(); attempts >= maxTOTPAttempts {
+			clearPendingLogin(c, session)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "验证失败次数过多，请稍后重新登录"})
+			return
+		}
+
+		if !verifyTOTP(&user, req.Code) {
+			attempts, _ := queue.Client.Incr(ctx, attemptsKey).Result()
+			if attempts == 1 {
+				queue.Client.Expire(ctx, attemptsKey, totpLoginWindow)
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误"})
+			return
+		}
+
+		queue.Client.Del(ctx, attemptsKey)
+		completeLogin(c, session, &user)
 	}
 }
 
+func completeLogin(c *gin.Context, session *sessions.Session, user *models.AdminUser) {
+	now := time.Now()
+	database.DB.Model(user).Update("last_login_at", now)
+
+	sessionstore.Regenerate(c.Request, session)
+	delete(session.Values, "pending_user_id")
+	delete(session.Values, "pending_at")
+	session.Values["user_id"] = user.ID
+	session.Values["session_version"] = user.SessionVersion
+	session.Save(c.Request, c.Writer)
+
+	c.JSON(http.StatusOK, gin.H{"message": "登录成功", "role": user.Role})
+}
+
+func clearPendingLogin(c *gin.Context, session *sessions.Session) {
+	delete(session.Values, "pending_user_id")
+	delete(session.Values, "pending_at")
+	session.Save(c.Request, c.Writer)
+}
+
 func HandleLogout() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		session, _ := store.Get(c.Request, "mailflow-session")
-		session.Values["authenticated"] = false
+		session, _ := store.Get(c.Request, sessionName)
+		delete(session.Values, "user_id")
 		session.Options.MaxAge = -1
 		session.Save(c.Request, c.Writer)
 		
@@ -93,3 +212,14 @@ func HandleLogout() gin.HandlerFunc {
 	}
 }
 
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func checkPassword(user *models.AdminUser, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"github.com/mailflow/smtp-loadbalancer/internal/totp"
+)
+
+const totpIssuer = "MailFlow"
+
+func setupMyTOTP(c *gin.Context) {
+	user := currentAdminUser(c)
+	if user == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "当前凭证未绑定账号"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "已启用两步验证，请先关闭后再重新绑定"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret": secret,
+		"uri":    totp.ProvisioningURI(totpIssuer, user.Username, secret),
+	})
+}
+
+func enableMyTOTP(c *gin.Context) {
+	user := currentAdminUser(c)
+	if user == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "当前凭证未绑定账号"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "已启用两步验证"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请先生成两步验证密钥"})
+		return
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now(), 0)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"totp_enabled":   true,
+		"totp_last_step": step,
+		"recovery_codes": hashes,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已启用", "recovery_codes": codes})
+}
+
+func disableMyTOTP(c *gin.Context) {
+	user := currentAdminUser(c)
+	if user == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "当前凭证未绑定账号"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未启用两步验证"})
+		return
+	}
+	if !checkPassword(user, req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "密码错误"})
+		return
+	}
+	if !verifyTOTP(user, req.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误"})
+		return
+	}
+
+	if err := clearTOTP(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已关闭"})
+}
+
+func regenerateMyRecoveryCodes(c *gin.Context) {
+	user := currentAdminUser(c)
+	if user == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "当前凭证未绑定账号"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未启用两步验证"})
+		return
+	}
+	if !verifyTOTP(user, req.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.DB.Model(user).Update("recovery_codes", hashes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func resetAdminUserTOTP(c *gin.Context) {
+	var user models.AdminUser
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	if err := clearTOTP(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已重置"})
+}
+
+func verifyTOTP(user *models.AdminUser, code string) bool {
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), user.TOTPLastStep); ok {
+		result := database.DB.Model(&models.AdminUser{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return false
+		}
+		user.TOTPLastStep = step
+		return true
+	}
+
+	if remaining, ok := totp.UseRecoveryCode(user.RecoveryCodes, code); ok {
+		result := database.DB.Model(&models.AdminUser{}).
+			Where("id = ? AND recovery_codes = ?", user.ID, user.RecoveryCodes).
+			Update("recovery_codes", remaining)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return false
+		}
+		user.RecoveryCodes = remaining
+		return true
+	}
+
+	return false
+}
+
+func newRecoveryCodes() ([]string, string, error) {
+	codes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, "", err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = totp.HashRecoveryCode(code)
+	}
+	return codes, strings.Join(hashes, ","), nil
+}
+
+func clearTOTP(user *models.AdminUser) error {
+	return database.DB.Model(user).Updates(map[string]interface{}{
+		"totp_secret":    "",
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+		"recovery_codes": "",
+	}).Error
+}
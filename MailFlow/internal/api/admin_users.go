@@ -0,0 +1,252 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"gorm.io/gorm"
+)
+
+const minPasswordLength = 8
+
+func listAdminUsers(c *gin.Context) {
+	var users []models.AdminUser
+	if err := database.DB.Order("created_at ASC").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+func createAdminUser(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Role     string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if !validRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色"})
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "密码长度不能少于8位"})
+		return
+	}
+
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		return
+	}
+
+	user := models.AdminUser{
+		Username:     req.Username,
+		PasswordHash: hash,
+		Role:         req.Role,
+		IsActive:     true,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func updateAdminUser(c *gin.Context) {
+	var user models.AdminUser
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	var req struct {
+		Role     *string `json:"role"`
+		Password *string `json:"password"`
+		IsActive *bool   `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if req.Role != nil {
+		if !validRole(*req.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色"})
+			return
+		}
+		user.Role = *req.Role
+	}
+	if req.IsActive != nil {
+		user.IsActive = *req.IsActive
+	}
+	if req.Password != nil {
+		if len(*req.Password) < minPasswordLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "密码长度不能少于8位"})
+			return
+		}
+		hash, err := hashPassword(*req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+			return
+		}
+		user.PasswordHash = hash
+		user.SessionVersion++
+	}
+
+	if (user.Role != RoleOwner || !user.IsActive) && !hasOtherOwner(user.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "至少需要保留一个启用的所有者账号"})
+		return
+	}
+
+	if err := database.DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func deleteAdminUser(c *gin.Context) {
+	var user models.AdminUser
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	if current := currentAdminUser(c); current != nil && current.ID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能删除当前登录的账号"})
+		return
+	}
+	if !hasOtherOwner(user.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "至少需要保留一个启用的所有者账号"})
+		return
+	}
+
+	if err := database.DB.Delete(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+	database.DB.Where("user_id = ?", user.ID).Delete(&models.AdminToken{})
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func getCurrentAdmin(c *gin.Context) {
+	role := c.GetString("admin_role")
+	user := currentAdminUser(c)
+
+	recoveryCodesLeft := 0
+	if user != nil && user.RecoveryCodes != "" {
+		recoveryCodesLeft = len(strings.Split(user.RecoveryCodes, ","))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":                user,
+		"role":                role,
+		"permissions":         rolePermissions[role],
+		"recovery_codes_left": recoveryCodesLeft,
+	})
+}
+
+func changeMyPassword(c *gin.Context) {
+	user := currentAdminUser(c)
+	if user == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "当前凭证未绑定账号"})
+		return
+	}
+
+	var req struct {
+		OldPassword string `json:"old_password" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if !checkPassword(user, req.OldPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "原密码错误"})
+		return
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "密码长度不能少于8位"})
+		return
+	}
+
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		return
+	}
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"password_hash":   hash,
+		"session_version": gorm.Expr("session_version + 1"),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	session, _ := store.Get(c.Request, sessionName)
+	if _, ok := session.Values["user_id"].(uint); ok {
+		var updated models.AdminUser
+		if err := database.DB.Select("session_version").First(&updated, user.ID).Error; err == nil {
+			session.Values["session_version"] = updated.SessionVersion
+			session.Save(c.Request, c.Writer)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码已修改，其他设备上的登录已失效"})
+}
+
+func revokeMySessions(c *gin.Context) {
+	user := currentAdminUser(c)
+	if user == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "当前凭证未绑定账号"})
+		return
+	}
+
+	if err := revokeSessions(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "操作失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "所有登录会话已失效，请重新登录"})
+}
+
+func revokeAdminUserSessions(c *gin.Context) {
+	var user models.AdminUser
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	if err := revokeSessions(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "操作失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "该账号的所有登录会话已失效"})
+}
+
+func revokeSessions(userID uint) error {
+	return database.DB.Model(&models.AdminUser{}).
+		Where("id = ?", userID).
+		Update("session_version", gorm.Expr("session_version + 1")).Error
+}
+
+func hasOtherOwner(excludeID uint) bool {
+	var count int64
+	database.DB.Model(&models.AdminUser{}).
+		Where("id <> ? AND role = ? AND is_active = ?", excludeID, RoleOwner, true).
+		Count(&count)
+	return count > 0
+}
@@ -1,32 +1,109 @@
 package api
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
 	"net/http"
+	"net/mail"
+	"net/textproto"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mailflow/smtp-loadbalancer/internal/auth"
+	"github.com/mailflow/smtp-loadbalancer/internal/config"
 	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/events"
 	"github.com/mailflow/smtp-loadbalancer/internal/models"
 	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/mailflow/smtp-loadbalancer/internal/render"
 	"github.com/mailflow/smtp-loadbalancer/internal/stats"
+	"github.com/mailflow/smtp-loadbalancer/internal/unsubscribe"
 )
 
 type SendEmailRequest struct {
-	To      []string `json:"to" binding:"required"`
-	Subject string   `json:"subject" binding:"required"`
-	HTML    string   `json:"html"`
-	Text    string   `json:"text"`
+	From        string                 `json:"from"`
+	To          []string               `json:"to" binding:"required"`
+	Cc          []string               `json:"cc"`
+	Bcc         []string               `json:"bcc"`
+	ReplyTo     string                 `json:"reply_to"`
+	Headers     map[string]string      `json:"headers"`
+	Subject     string                 `json:"subject"`
+	HTML        string                 `json:"html"`
+	Text        string                 `json:"text"`
+	TemplateID  *uint                  `json:"template_id"`
+	Variables   map[string]interface{} `json:"variables"`
+	SendAt      string                 `json:"send_at"`
+	Category    string                 `json:"category"`
+	Attachments []AttachmentRequest    `json:"attachments" binding:"dive"`
 }
 
-func RegisterAPIKeyAPI(r *gin.Engine) {
+type AttachmentRequest struct {
+	Filename    string `json:"filename" binding:"required"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content" binding:"required"`
+	ContentID   string `json:"content_id"`
+}
+
+var (
+	maxAttachmentSize int
+	maxBatchSize      int
+	keyRotationGrace  time.Duration
+)
+
+var allowedHeaders = map[string]bool{
+	"List-Unsubscribe":      true,
+	"List-Unsubscribe-Post": true,
+	"List-Id":               true,
+	"Precedence":            true,
+	"Auto-Submitted":        true,
+	"In-Reply-To":           true,
+	"References":            true,
+}
+
+const (
+	customHeaderPrefix   = "X-"
+	reservedHeaderPrefix = "X-Mailflow-"
+)
+
+func RegisterAPIKeyAPI(r *gin.Engine, cfg *config.Config) {
+	maxAttachmentSize = cfg.Server.MaxAttachmentSize
+	maxBatchSize = cfg.Server.MaxBatchSize
+	keyRotationGrace = cfg.Server.KeyRotationGrace
+
 	apikey := r.Group("/api/v1")
 	apikey.Use(auth.AuthMiddleware())
 	{
-		apikey.POST("/send", handleSendEmail)
-		apikey.GET("/quota", getMyQuota)
-		apikey.GET("/usage", getMyUsage)
-		apikey.GET("/logs", getMyLogs)
+		apikey.POST("/send", auth.RequireScope(auth.ScopeSend), handleSendEmail)
+		apikey.POST("/send/batch", auth.RequireScope(auth.ScopeSend), handleBatchSend)
+		apikey.GET("/messages/:id", auth.RequireScope(auth.ScopeMessagesRead), getMessageStatus)
+		apikey.DELETE("/messages/:id", auth.RequireScope(auth.ScopeSend), cancelMessage)
+		apikey.POST("/key/rotate", auth.RequireScope(auth.ScopeKeyRotate), rotateMyAPIKey)
+		apikey.GET("/quota", auth.RequireScope(auth.ScopeUsageRead), getMyQuota)
+		apikey.GET("/usage", auth.RequireScope(auth.ScopeUsageRead), getMyUsage)
+		apikey.GET("/logs", auth.RequireScope(auth.ScopeLogsRead), getMyLogs)
+		apikey.GET("/identities", auth.RequireScope(auth.ScopeIdentitiesRead), getMyIdentities)
+		apikey.GET("/templates", auth.RequireScope(auth.ScopeTemplatesRead), listMyTemplates)
+		apikey.GET("/templates/:id", auth.RequireScope(auth.ScopeTemplatesRead), getMyTemplate)
+		apikey.POST("/templates", auth.RequireScope(auth.ScopeTemplatesWrite), createMyTemplate)
+		apikey.PUT("/templates/:id", auth.RequireScope(auth.ScopeTemplatesWrite), updateMyTemplate)
+		apikey.DELETE("/templates/:id", auth.RequireScope(auth.ScopeTemplatesWrite), deleteMyTemplate)
+		apikey.GET("/webhooks", auth.RequireScope(auth.ScopeWebhooksRead), listMyWebhooks)
+		apikey.POST("/webhooks", auth.RequireScope(auth.ScopeWebhooksWrite), createMyWebhook)
+		apikey.PUT("/webhooks/:id", auth.RequireScope(auth.ScopeWebhooksWrite), updateMyWebhook)
+		apikey.DELETE("/webhooks/:id", auth.RequireScope(auth.ScopeWebhooksWrite), deleteMyWebhook)
+		apikey.POST("/webhooks/:id/secret", auth.RequireScope(auth.ScopeWebhooksWrite), rotateMyWebhookSecret)
+		apikey.POST("/webhooks/:id/test", auth.RequireScope(auth.ScopeWebhooksWrite), testMyWebhook)
+		apikey.GET("/webhooks/:id/deliveries", auth.RequireScope(auth.ScopeWebhooksRead), listMyWebhookDeliveries)
+		apikey.GET("/suppressions", auth.RequireScope(auth.ScopeSuppressionsRead), listMySuppressions)
+		apikey.POST("/suppressions", auth.RequireScope(auth.ScopeSuppressionsWrite), createMySuppression)
+		apikey.POST("/suppressions/import", auth.RequireScope(auth.ScopeSuppressionsWrite), importMySuppressions)
+		apikey.DELETE("/suppressions/:id", auth.RequireScope(auth.ScopeSuppressionsWrite), deleteMySuppression)
 	}
 }
 
@@ -37,32 +114,538 @@ func handleSendEmail(c *gin.Context) {
 		return
 	}
 
-	if req.HTML == "" && req.Text == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "必须提供html或text内容"})
+	apiKeyID, _ := c.Get("api_key_id")
+
+	task, sendAt, code, err := prepareTask(c.Request.Context(), apiKeyID.(uint), &req, templateCache{})
+	if err != nil {
+		c.JSON(code, gin.H{"error": err.Error()})
 		return
 	}
 
-	apiKeyID, _ := c.Get("api_key_id")
+	if !reserveQuota(c, []*queue.EmailTask{task}) {
+		discardTasks(c.Request.Context(), []*queue.EmailTask{task})
+		return
+	}
 
-	task := &queue.EmailTask{
-		APIKeyID: apiKeyID.(uint),
-		To:       req.To,
-		Subject:  req.Subject,
-		HTML:     req.HTML,
-		Text:     req.Text,
+	status := "queued"
+	if !sendAt.IsZero() {
+		status = "scheduled"
+	}
+
+	if err := createQueuedLogs(task, status); err != nil {
+		discardTasks(c.Request.Context(), []*queue.EmailTask{task})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建发送记录失败"})
+		return
 	}
 
-	if err := queue.PushEmail(c.Request.Context(), task); err != nil {
+	if sendAt.IsZero() {
+		err = queue.PushEmail(c.Request.Context(), task)
+	} else {
+		err = queue.ScheduleEmail(c.Request.Context(), task.MessageID, task, sendAt)
+	}
+	if err != nil {
+		database.DB.Where("message_id = ?", task.MessageID).Delete(&models.SendLog{})
+		discardTasks(c.Request.Context(), []*queue.EmailTask{task})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "邮件入队失败"})
 		return
 	}
+	publishQueued(c.Request.Context(), task, sendAt)
+
+	resp := gin.H{
+		"message":    "邮件已加入发送队列",
+		"count":      len(task.Recipients),
+		"message_id": task.MessageID,
+		"recipients": task.Recipients,
+	}
+	if !sendAt.IsZero() {
+		resp["message"] = "邮件已加入定时发送队列"
+		resp["send_at"] = sendAt
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func prepareTask(ctx context.Context, apiKeyID uint, req *SendEmailRequest, templates templateCache) (*queue.EmailTask, time.Time, int, error) {
+	var sendAt time.Time
+
+	if len(req.To) == 0 {
+		return nil, sendAt, http.StatusBadRequest, fmt.Errorf("必须提供收件人to")
+	}
+	if err := validateRecipients(req); err != nil {
+		return nil, sendAt, http.StatusBadRequest, err
+	}
+
+	if req.TemplateID != nil {
+		tpl, err := templates.get(apiKeyID, *req.TemplateID)
+		if err != nil {
+			return nil, sendAt, http.StatusBadRequest, err
+		}
+		if err := applyTemplate(tpl, req); err != nil {
+			return nil, sendAt, http.StatusBadRequest, err
+		}
+	}
+
+	if req.Subject == "" {
+		return nil, sendAt, http.StatusBadRequest, fmt.Errorf("必须提供subject或template_id")
+	}
+
+	if req.HTML == "" && req.Text == "" {
+		return nil, sendAt, http.StatusBadRequest, fmt.Errorf("必须提供html或text内容")
+	}
+
+	if req.ReplyTo != "" {
+		if _, err := mail.ParseAddress(req.ReplyTo); err != nil {
+			return nil, sendAt, http.StatusBadRequest, fmt.Errorf("reply_to不是有效的邮箱地址")
+		}
+	}
+
+	headers, err := validateHeaders(req.Headers)
+	if err != nil {
+		return nil, sendAt, http.StatusBadRequest, err
+	}
+
+	switch req.Category {
+	case "", queue.CategoryTransactional:
+	case queue.CategoryMarketing:
+		if !unsubscribe.Enabled() {
+			return nil, sendAt, http.StatusBadRequest, fmt.Errorf("服务器未配置退订地址，无法发送营销邮件")
+		}
+	default:
+		return nil, sendAt, http.StatusBadRequest, fmt.Errorf("category必须是transactional或marketing")
+	}
+
+	if req.SendAt != "" {
+		t, err := time.Parse(time.RFC3339, req.SendAt)
+		if err != nil {
+			return nil, sendAt, http.StatusBadRequest, fmt.Errorf("send_at必须是RFC3339格式的时间")
+		}
+		if t.After(time.Now().Add(queue.MaxScheduleDelay)) {
+			return nil, sendAt, http.StatusBadRequest, fmt.Errorf("send_at不能超过30天")
+		}
+		if t.After(time.Now()) {
+			sendAt = t
+		}
+	}
+
+	var identity models.SenderIdentity
+	if req.From != "" {
+		identity, err = findVerifiedIdentity(apiKeyID, req.From)
+		if err != nil {
+			return nil, sendAt, http.StatusForbidden, err
+		}
+	}
+
+	task := &queue.EmailTask{
+		APIKeyID:  apiKeyID,
+		FromEmail: identity.Email,
+		FromName:  identity.Name,
+		To:        req.To,
+		Cc:        req.Cc,
+		Bcc:       req.Bcc,
+		ReplyTo:   req.ReplyTo,
+		Headers:   headers,
+		Subject:   req.Subject,
+		HTML:      req.HTML,
+		Text:      req.Text,
+		Category:  req.Category,
+	}
+	task.AssignIDs()
+
+	if len(req.Attachments) > 0 {
+		blobTTL := queue.BlobTTL
+		if !sendAt.IsZero() {
+			blobTTL += time.Until(sendAt)
+		}
+
+		attachments, err := storeAttachments(ctx, task, req.Attachments, blobTTL)
+		if err != nil {
+			return nil, sendAt, http.StatusBadRequest, err
+		}
+		task.Attachments = attachments
+	}
+
+	return task, sendAt, http.StatusOK, nil
+}
+
+func reserveQuota(c *gin.Context, tasks []*queue.EmailTask) bool {
+	value, _ := c.Get("api_key")
+	key := value.(*auth.CachedAPIKey)
+
+	total := 0
+	for _, task := range tasks {
+		total += len(task.Recipients)
+	}
+
+	ok, msg, limit, err := auth.ReserveQuota(c.Request.Context(), key, total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if limit != nil {
+		auth.SetRateLimitHeaders(c, limit)
+	}
+	if !ok {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": msg})
+		return false
+	}
+
+	now := time.Now()
+	for _, task := range tasks {
+		task.QuotaReservedAt = &now
+	}
+	return true
+}
+
+func refundTasks(ctx context.Context, tasks []*queue.EmailTask) {
+	for _, task := range tasks {
+		if task.QuotaReservedAt != nil {
+			auth.RefundQuota(ctx, task.APIKeyID, len(task.Recipients), *task.QuotaReservedAt)
+		}
+	}
+}
+
+func discardTasks(ctx context.Context, tasks []*queue.EmailTask) {
+	refundTasks(ctx, tasks)
+
+	var keys []string
+	for _, task := range tasks {
+		for _, a := range task.Attachments {
+			keys = append(keys, a.BlobKey)
+		}
+	}
+	if err := queue.DeleteBlobs(ctx, keys...); err != nil {
+		log.Printf("删除附件失败: %v", err)
+	}
+}
+
+type templateCache map[uint]*models.Template
+
+func (tc templateCache) get(apiKeyID, id uint) (*models.Template, error) {
+	if tpl, ok := tc[id]; ok {
+		return tpl, nil
+	}
+
+	var tpl models.Template
+	if err := database.DB.Where("id = ? AND (api_key_id = ? OR api_key_id IS NULL)", id, apiKeyID).First(&tpl).Error; err != nil {
+		return nil, fmt.Errorf("模板不存在")
+	}
+	tc[id] = &tpl
+	return &tpl, nil
+}
+
+func applyTemplate(tpl *models.Template, req *SendEmailRequest) error {
+	result, err := render.Render(tpl, req.Variables)
+	if err != nil {
+		return err
+	}
+
+	req.Subject = result.Subject
+	req.HTML = result.HTML
+	req.Text = result.Text
+	return nil
+}
+
+func validateRecipients(req *SendEmailRequest) error {
+	fields := []struct {
+		name      string
+		addresses []string
+	}{
+		{"to", req.To},
+		{"cc", req.Cc},
+		{"bcc", req.Bcc},
+	}
+
+	for _, field := range fields {
+		for _, address := range field.addresses {
+			if _, err := mail.ParseAddress(address); err != nil {
+				return fmt.Errorf("无效的收件人地址(%s): %s", field.name, address)
+			}
+		}
+	}
+	return nil
+}
+
+func validateHeaders(headers map[string]string) (map[string]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]string, len(headers))
+	for name, value := range headers {
+		key := textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+		if !validHeaderName(key) || strings.IndexFunc(value, invalidHeaderRune) >= 0 {
+			return nil, fmt.Errorf("邮件头格式无效: %s", name)
+		}
+		if strings.HasPrefix(key, reservedHeaderPrefix) {
+			return nil, fmt.Errorf("邮件头%s为系统保留，不能自定义", name)
+		}
+		if !allowedHeaders[key] && (!strings.HasPrefix(key, customHeaderPrefix) || key == customHeaderPrefix) {
+			return nil, fmt.Errorf("不允许自定义邮件头: %s", name)
+		}
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r > '~' || r == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+func invalidHeaderRune(r rune) bool {
+	return r != '\t' && unicode.IsControl(r)
+}
+
+func storeAttachments(ctx context.Context, task *queue.EmailTask, reqs []AttachmentRequest, ttl time.Duration) ([]queue.Attachment, error) {
+	limit, err := attachmentLimit(task.APIKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := make([][]byte, 0, len(reqs))
+	total := 0
+	for _, a := range reqs {
+		if strings.TrimSpace(a.Filename) == "" || a.Content == "" {
+			return nil, fmt.Errorf("附件必须提供filename和content")
+		}
+		if a.ContentType != "" {
+			if _, _, err := mime.ParseMediaType(a.ContentType); err != nil {
+				return nil, fmt.Errorf("附件[%s]的content_type无效", a.Filename)
+			}
+		}
+
+		data, err := base64.StdEncoding.DecodeString(a.Content)
+		if err != nil {
+			return nil, fmt.Errorf("附件[%s]不是有效的base64内容", a.Filename)
+		}
+		total += len(data)
+		if total > limit {
+			return nil, fmt.Errorf("附件总大小超过限制: %dMB", limit/1024/1024)
+		}
+		blobs = append(blobs, data)
+	}
+
+	attachments := make([]queue.Attachment, 0, len(reqs))
+	keys := make([]string, 0, len(reqs))
+	for i, a := range reqs {
+		key := fmt.Sprintf("%s:%d", task.MessageID, i)
+		if err := queue.StoreBlob(ctx, key, blobs[i], ttl); err != nil {
+			queue.DeleteBlobs(ctx, keys...)
+			return nil, fmt.Errorf("保存附件失败")
+		}
+		keys = append(keys, key)
+		attachments = append(attachments, queue.Attachment{
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			ContentID:   strings.Trim(a.ContentID, "<>"),
+			Size:        len(blobs[i]),
+			BlobKey:     key,
+		})
+	}
+
+	return attachments, nil
+}
+
+func attachmentLimit(apiKeyID uint) (int, error) {
+	limit := maxAttachmentSize
+
+	var key models.APIKey
+	if err := database.DB.First(&key, apiKeyID).Error; err != nil {
+		return 0, fmt.Errorf("API Key不存在")
+	}
+
+	if key.PlanID != nil {
+		var plan models.Plan
+		if err := database.DB.First(&plan, *key.PlanID).Error; err == nil && plan.MaxAttachmentSize > 0 && plan.MaxAttachmentSize < limit {
+			limit = plan.MaxAttachmentSize
+		}
+	}
+
+	return limit * 1024 * 1024, nil
+}
+
+func createQueuedLogs(task *queue.EmailTask, status string) error {
+	logs := buildQueuedLogs(task, status, time.Now())
+	return database.DB.CreateInBatches(&logs, 500).Error
+}
+
+func buildQueuedLogs(task *queue.EmailTask, status string, now time.Time) []models.SendLog {
+	logs := make([]models.SendLog, 0, len(task.Recipients))
+	for _, recipient := range task.Recipients {
+		logs = append(logs, models.SendLog{
+			MessageID:   task.MessageID,
+			RecipientID: recipient.ID,
+			APIKeyID:    task.APIKeyID,
+			To:          recipient.Email,
+			Subject:     task.Subject,
+			Status:      status,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+	}
+	return logs
+}
+
+func publishQueued(ctx context.Context, task *queue.EmailTask, sendAt time.Time) {
+	data := map[string]interface{}{
+		"message_id": task.MessageID,
+		"subject":    task.Subject,
+		"recipients": task.Recipients,
+	}
+	if !sendAt.IsZero() {
+		data["send_at"] = sendAt
+	}
+	if err := events.Publish(ctx, events.EmailQueued, task.APIKeyID, data); err != nil {
+		log.Printf("发布入队事件失败 [%s]: %v", task.MessageID, err)
+	}
+}
+
+func getMessageStatus(c *gin.Context) {
+	apiKeyID, exists := c.Get("api_key_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	var logs []models.SendLog
+	if err := database.DB.Where("message_id = ? AND api_key_id = ?", c.Param("id"), apiKeyID).
+		Order("id ASC").Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	if len(logs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "邮件不存在"})
+		return
+	}
+
+	smtpIDs := make([]uint, 0)
+	for _, l := range logs {
+		if l.SMTPConfigID > 0 {
+			smtpIDs = append(smtpIDs, l.SMTPConfigID)
+		}
+	}
+
+	smtpNames := make(map[uint]string)
+	if len(smtpIDs) > 0 {
+		var configs []models.SMTPConfig
+		database.DB.Select("id", "name").Where("id IN ?", smtpIDs).Find(&configs)
+		for _, config := range configs {
+			smtpNames[config.ID] = config.Name
+		}
+	}
+
+	counts := make(map[string]int)
+	recipients := make([]gin.H, 0, len(logs))
+	for _, l := range logs {
+		status := messageStatus(l.Status)
+		counts[status]++
+
+		recipient := gin.H{
+			"id":         l.RecipientID,
+			"email":      l.To,
+			"status":     status,
+			"error":      l.ErrorMsg,
+			"updated_at": l.UpdatedAt,
+		}
+		if l.SMTPConfigID > 0 {
+			recipient["smtp_config_id"] = l.SMTPConfigID
+			recipient["smtp_name"] = smtpNames[l.SMTPConfigID]
+		}
+		recipients = append(recipients, recipient)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "邮件已加入发送队列",
-		"count":   len(req.To),
+		"message_id": logs[0].MessageID,
+		"subject":    logs[0].Subject,
+		"status":     aggregateMessageStatus(counts, len(logs)),
+		"created_at": logs[0].CreatedAt,
+		"recipients": recipients,
 	})
 }
 
+func cancelMessage(c *gin.Context) {
+	apiKeyID, exists := c.Get("api_key_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	messageID := c.Param("id")
+
+	var count int64
+	database.DB.Model(&models.SendLog{}).
+		Where("message_id = ? AND api_key_id = ?", messageID, apiKeyID).
+		Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "邮件不存在"})
+		return
+	}
+
+	task, err := queue.CancelScheduled(c.Request.Context(), messageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取消失败"})
+		return
+	}
+	if task == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "邮件不是待发送的定时邮件，无法取消"})
+		return
+	}
+	discardTasks(c.Request.Context(), []*queue.EmailTask{task})
+
+	database.DB.Model(&models.SendLog{}).
+		Where("message_id = ? AND status = ?", messageID, "scheduled").
+		Updates(map[string]interface{}{
+			"status":     "cancelled",
+			"updated_at": time.Now(),
+		})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "定时邮件已取消",
+		"message_id": messageID,
+	})
+}
+
+func messageStatus(status string) string {
+	if status == "success" {
+		return "sent"
+	}
+	return status
+}
+
+func aggregateMessageStatus(counts map[string]int, total int) string {
+	switch {
+	case counts["sending"] > 0:
+		return "sending"
+	case counts["retrying"] > 0:
+		return "retrying"
+	case counts["queued"] > 0:
+		return "queued"
+	case counts["scheduled"] > 0:
+		return "scheduled"
+	case counts["cancelled"] == total:
+		return "cancelled"
+	case counts["sent"] == total:
+		return "sent"
+	case counts["failed"] == total:
+		return "failed"
+	case counts["bounced"] == total:
+		return "bounced"
+	case counts["suppressed"] == total:
+		return "suppressed"
+	default:
+		return "partial"
+	}
+}
+
 func getMyQuota(c *gin.Context) {
 	apiKeyID, exists := c.Get("api_key_id")
 	if !exists {
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRecipients(t *testing.T) {
+	tests := []struct {
+		name string
+		req  SendEmailRequest
+		want string
+	}{
+		{"valid", SendEmailRequest{To: []string{"a@example.com", "Bob <b@example.com>"}, Cc: []string{"c@example.com"}, Bcc: []string{"d@example.com"}}, ""},
+		{"invalid to", SendEmailRequest{To: []string{"a@example.com", "not-an-address"}}, "(to): not-an-address"},
+		{"invalid cc", SendEmailRequest{To: []string{"a@example.com"}, Cc: []string{"c@"}}, "(cc): c@"},
+		{"invalid bcc", SendEmailRequest{To: []string{"a@example.com"}, Bcc: []string{""}}, "(bcc): "},
+		{"header injection", SendEmailRequest{To: []string{"a@example.com\r\nBcc: x@example.com"}}, "(to)"},
+	}
+
+	for _, tt := range tests {
+		err := validateRecipients(&tt.req)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestValidateHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantKey string
+		wantErr bool
+	}{
+		{"allowlisted", map[string]string{"list-id": "<news.example.com>"}, "List-Id", false},
+		{"custom x header", map[string]string{"x-campaign": "spring"}, "X-Campaign", false},
+		{"tab in value", map[string]string{"X-Note": "a\tb"}, "X-Note", false},
+		{"not allowlisted", map[string]string{"Bcc": "x@example.com"}, "", true},
+		{"bare prefix", map[string]string{"X-": "1"}, "", true},
+		{"reserved namespace", map[string]string{"X-MailFlow-Signature": "forged"}, "", true},
+		{"reserved namespace any case", map[string]string{"x-mailflow-event": "forged"}, "", true},
+		{"crlf in value", map[string]string{"X-Campaign": "a\r\nBcc: x@example.com"}, "", true},
+		{"lf in value", map[string]string{"X-Campaign": "a\nb"}, "", true},
+		{"nul in value", map[string]string{"X-Campaign": "a\x00b"}, "", true},
+		{"crlf in name", map[string]string{"X-A\r\nBcc": "x@example.com"}, "", true},
+		{"colon in name", map[string]string{"X-A:B": "1"}, "", true},
+		{"space in name", map[string]string{"X-A B": "1"}, "", true},
+	}
+
+	for _, tt := range tests {
+		result, err := validateHeaders(tt.headers)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantKey != "" {
+			if _, ok := result[tt.wantKey]; !ok {
+				t.Errorf("%s: result %v missing %s", tt.name, result, tt.wantKey)
+			}
+		}
+	}
+
+	if result, err := validateHeaders(nil); result != nil || err != nil {
+		t.Errorf("empty headers = %v, %v", result, err)
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+)
+
+type BatchSendRequest struct {
+	From       string             `json:"from"`
+	TemplateID *uint              `json:"template_id"`
+	Messages   []SendEmailRequest `json:"messages" binding:"required"`
+}
+
+type BatchSendResult struct {
+	Index      int               `json:"index"`
+	MessageID  string            `json:"message_id,omitempty"`
+	Recipients []queue.Recipient `json:"recipients,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+func handleBatchSend(c *gin.Context) {
+	var req BatchSendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if len(req.Messages) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "messages不能为空"})
+		return
+	}
+	if len(req.Messages) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("单次最多提交%d封邮件", maxBatchSize)})
+		return
+	}
+
+	apiKeyID, _ := c.Get("api_key_id")
+	ctx := c.Request.Context()
+	templates := templateCache{}
+
+	results := make([]BatchSendResult, len(req.Messages))
+	tasks := make([]*queue.EmailTask, 0, len(req.Messages))
+	sendAts := make([]time.Time, 0, len(req.Messages))
+	accepted := make([]int, 0, len(req.Messages))
+
+	for i := range req.Messages {
+		msg := &req.Messages[i]
+		if msg.From == "" {
+			msg.From = req.From
+		}
+		if msg.TemplateID == nil {
+			msg.TemplateID = req.TemplateID
+		}
+
+		results[i].Index = i
+		task, sendAt, _, err := prepareTask(ctx, apiKeyID.(uint), msg, templates)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		tasks = append(tasks, task)
+		sendAts = append(sendAts, sendAt)
+		accepted = append(accepted, i)
+	}
+
+	if len(tasks) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "没有可发送的邮件",
+			"accepted": 0,
+			"rejected": len(results),
+			"results":  results,
+		})
+		return
+	}
+
+	if !reserveQuota(c, tasks) {
+		discardTasks(ctx, tasks)
+		return
+	}
+
+	now := time.Now()
+	logs := make([]models.SendLog, 0, len(tasks))
+	messageIDs := make([]string, 0, len(tasks))
+	for i, task := range tasks {
+		status := "queued"
+		if !sendAts[i].IsZero() {
+			status = "scheduled"
+		}
+		logs = append(logs, buildQueuedLogs(task, status, now)...)
+		messageIDs = append(messageIDs, task.MessageID)
+	}
+
+	if err := database.DB.CreateInBatches(&logs, 500).Error; err != nil {
+		discardTasks(ctx, tasks)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建发送记录失败"})
+		return
+	}
+
+	if err := queue.EnqueueBatch(ctx, tasks, sendAts); err != nil {
+		database.DB.Where("message_id IN ?", messageIDs).Delete(&models.SendLog{})
+		discardTasks(ctx, tasks)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "邮件入队失败"})
+		return
+	}
+
+	for i, idx := range accepted {
+		publishQueued(ctx, tasks[i], sendAts[i])
+		results[idx].MessageID = tasks[i].MessageID
+		results[idx].Recipients = tasks[i].Recipients
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "批量邮件已加入发送队列",
+		"accepted": len(tasks),
+		"rejected": len(results) - len(tasks),
+		"results":  results,
+	})
+}
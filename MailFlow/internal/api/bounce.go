@@ -0,0 +1,76 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/bounce"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+func normalizeBounceSettings(config *models.SMTPConfig) error {
+	config.ReturnPath = strings.TrimSpace(config.ReturnPath)
+	if config.ReturnPath != "" {
+		addr, err := mail.ParseAddress(config.ReturnPath)
+		if err != nil || addr.Address != config.ReturnPath {
+			return fmt.Errorf("退信地址格式无效")
+		}
+	}
+
+	config.BounceProtocol = strings.ToLower(strings.TrimSpace(config.BounceProtocol))
+	switch config.BounceProtocol {
+	case "":
+		return nil
+	case bounce.ProtocolIMAP, bounce.ProtocolPOP3:
+	default:
+		return fmt.Errorf("退信邮箱协议必须是imap或pop3")
+	}
+
+	config.BounceSecurity = strings.ToLower(strings.TrimSpace(config.BounceSecurity))
+	switch config.BounceSecurity {
+	case "":
+		config.BounceSecurity = "ssl"
+	case "ssl", "starttls", "none":
+	default:
+		return fmt.Errorf("退信邮箱加密方式必须是ssl、starttls或none")
+	}
+
+	config.BounceHost = strings.TrimSpace(config.BounceHost)
+	if config.BounceHost == "" || config.BounceUsername == "" {
+		return fmt.Errorf("请填写退信邮箱服务器和用户名")
+	}
+	if config.BouncePort < 0 || config.BouncePort > 65535 {
+		return fmt.Errorf("退信邮箱端口无效")
+	}
+	return nil
+}
+
+func testBounceMailbox(c *gin.Context) {
+	var config models.SMTPConfig
+	if err := database.DB.First(&config, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SMTP配置不存在"})
+		return
+	}
+	if config.BounceProtocol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未配置退信邮箱"})
+		return
+	}
+
+	if err := bounce.Test(&config); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "连接失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "连接成功",
+	})
+}
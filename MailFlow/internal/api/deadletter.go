@@ -0,0 +1,170 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/deadletter"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+func listDeadLetters(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	search := c.Query("search")
+	apiKeyID, _ := strconv.ParseUint(c.DefaultQuery("key_id", "0"), 10, 32)
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+
+	query := database.DB.Model(&models.DeadLetter{})
+
+	if apiKeyID > 0 {
+		query = query.Where("api_key_id = ?", apiKeyID)
+	}
+
+	if search != "" {
+		query = query.Where("\"to\" ILIKE ? OR subject ILIKE ? OR message_id = ?", "%"+search+"%", "%"+search+"%", search)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var letters []models.DeadLetter
+	offset := (page - 1) * pageSize
+	if err := query.Omit("payload", "errors").Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&letters).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"data":      letters,
+	})
+}
+
+func getDeadLetter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var letter models.DeadLetter
+	if err := database.DB.First(&letter, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "死信不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, letter)
+}
+
+func replayDeadLetter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	if err := deadletter.Replay(c.Request.Context(), uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已重新入队"})
+}
+
+func batchReplayDeadLetters(c *gin.Context) {
+	var req struct {
+		IDs []uint `json:"ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	replayed := 0
+	failed := make([]gin.H, 0)
+	for _, id := range req.IDs {
+		if err := deadletter.Replay(c.Request.Context(), id); err != nil {
+			failed = append(failed, gin.H{"id": id, "error": err.Error()})
+			continue
+		}
+		replayed++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "批量重新入队完成",
+		"replayed": replayed,
+		"failed":   failed,
+	})
+}
+
+func deleteDeadLetter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	if err := database.DB.Delete(&models.DeadLetter{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func batchDeleteDeadLetters(c *gin.Context) {
+	var req struct {
+		IDs []uint `json:"ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if err := database.DB.Delete(&models.DeadLetter{}, req.IDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "批量删除失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "批量删除成功"})
+}
+
+func purgeDeadLetters(c *gin.Context) {
+	var req struct {
+		Before string `json:"before"`
+	}
+	c.ShouldBindJSON(&req)
+
+	query := database.DB.Where("1 = 1")
+	if req.Before != "" {
+		before, err := time.ParseInLocation("2006-01-02", req.Before, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的日期"})
+			return
+		}
+		query = database.DB.Where("created_at < ?", before)
+	}
+
+	result := query.Delete(&models.DeadLetter{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "清空失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "清空成功",
+		"count":   result.RowsAffected,
+	})
+}
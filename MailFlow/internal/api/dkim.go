@@ -0,0 +1,145 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/dkim"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+type DKIMKeyRequest struct {
+	Domain     string `json:"domain" binding:"required"`
+	Selector   string `json:"selector" binding:"required"`
+	Algorithm  string `json:"algorithm"`
+	KeySize    int    `json:"key_size"`
+	PrivateKey string `json:"private_key"`
+}
+
+type DKIMKeyResponse struct {
+	models.DKIMKey
+	DNSName   string `json:"dns_name"`
+	DNSRecord string `json:"dns_record"`
+}
+
+func listDKIMKeys(c *gin.Context) {
+	query := database.DB.Order("domain ASC, created_at DESC")
+	if domain := strings.ToLower(strings.TrimSpace(c.Query("domain"))); domain != "" {
+		query = query.Where("domain = ?", domain)
+	}
+
+	var keys []models.DKIMKey
+	if err := query.Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	result := make([]DKIMKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, dkimKeyResponse(key))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func createDKIMKey(c *gin.Context) {
+	var req DKIMKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	domain, selector, err := dkim.Normalize(req.Domain, req.Selector)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.DKIMKey{}).Where("domain = ? AND selector = ?", domain, selector).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "该域名下已存在相同的selector"})
+		return
+	}
+
+	privateKey := strings.TrimSpace(req.PrivateKey)
+	if privateKey == "" {
+		algorithm := req.Algorithm
+		if algorithm == "" {
+			algorithm = dkim.AlgorithmRSA
+		}
+		if privateKey, err = dkim.Generate(algorithm, req.KeySize); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	signer, algorithm, err := dkim.ParsePrivateKey(privateKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Algorithm != "" && req.Algorithm != algorithm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "私钥类型与algorithm不一致"})
+		return
+	}
+	publicKey, err := dkim.PublicKey(signer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := models.DKIMKey{
+		Domain:     domain,
+		Selector:   selector,
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+		IsActive:   true,
+	}
+	if err := database.DB.Create(&key).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dkimKeyResponse(key))
+}
+
+func toggleDKIMKey(c *gin.Context) {
+	var key models.DKIMKey
+	if err := database.DB.First(&key, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "DKIM密钥不存在"})
+		return
+	}
+
+	key.IsActive = !key.IsActive
+	if err := database.DB.Model(&key).Update("is_active", key.IsActive).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dkimKeyResponse(key))
+}
+
+func deleteDKIMKey(c *gin.Context) {
+	result := database.DB.Delete(&models.DKIMKey{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "DKIM密钥不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func dkimKeyResponse(key models.DKIMKey) DKIMKeyResponse {
+	return DKIMKeyResponse{
+		DKIMKey:   key,
+		DNSName:   dkim.RecordName(&key),
+		DNSRecord: dkim.Record(&key),
+	}
+}
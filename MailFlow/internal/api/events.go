@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/events"
+)
+
+func listEvents(c *gin.Context) {
+	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	list, err := events.List(c.Request.Context(), c.Query("after"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	next := c.Query("after")
+	if len(list) > 0 {
+		next = list[len(list)-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": list,
+		"next": next,
+	})
+}
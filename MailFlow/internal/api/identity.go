@@ -0,0 +1,174 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+type IdentityRequest struct {
+	Email    string  `json:"email"`
+	Name     *string `json:"name"`
+	Verified *bool   `json:"verified"`
+}
+
+func findVerifiedIdentity(apiKeyID uint, from string) (models.SenderIdentity, error) {
+	var identity models.SenderIdentity
+
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return identity, fmt.Errorf("from不是有效的邮箱地址")
+	}
+
+	if err := database.DB.Where("api_key_id = ? AND LOWER(email) = ?", apiKeyID, strings.ToLower(addr.Address)).First(&identity).Error; err != nil {
+		return identity, fmt.Errorf("发件地址未绑定到当前API Key: %s", addr.Address)
+	}
+
+	if !identity.Verified {
+		return identity, fmt.Errorf("发件地址尚未验证: %s", identity.Email)
+	}
+
+	if addr.Name != "" {
+		identity.Name = addr.Name
+	}
+
+	return identity, nil
+}
+
+func listIdentities(c *gin.Context) {
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var identities []models.SenderIdentity
+	if err := database.DB.Where("api_key_id = ?", keyID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+func createIdentity(c *gin.Context) {
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var key models.APIKey
+	if err := database.DB.First(&key, keyID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API Key不存在"})
+		return
+	}
+
+	var req IdentityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	addr, err := mail.ParseAddress(req.Email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的邮箱地址"})
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.SenderIdentity{}).
+		Where("api_key_id = ? AND LOWER(email) = ?", key.ID, strings.ToLower(addr.Address)).
+		Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "发件地址已存在"})
+		return
+	}
+
+	identity := models.SenderIdentity{
+		APIKeyID: key.ID,
+		Email:    addr.Address,
+	}
+	if req.Name != nil {
+		identity.Name = *req.Name
+	}
+	if req.Verified != nil {
+		identity.Verified = *req.Verified
+	}
+
+	if err := database.DB.Create(&identity).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, identity)
+}
+
+func updateIdentity(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	var identity models.SenderIdentity
+	if err := database.DB.First(&identity, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "发件身份不存在"})
+		return
+	}
+
+	var req IdentityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Verified != nil {
+		updates["verified"] = *req.Verified
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusOK, identity)
+		return
+	}
+
+	if err := database.DB.Model(&identity).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, identity)
+}
+
+func deleteIdentity(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := database.DB.Delete(&models.SenderIdentity{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func getMyIdentities(c *gin.Context) {
+	apiKeyID, _ := c.Get("api_key_id")
+
+	var identities []models.SenderIdentity
+	if err := database.DB.Where("api_key_id = ?", apiKeyID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
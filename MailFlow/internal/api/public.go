@@ -13,6 +13,9 @@ func RegisterPublicAPI(r *gin.Engine) {
 	{
 		public.GET("/plans", getPublicPlans)
 	}
+
+	r.GET("/unsubscribe/:token", unsubscribePage)
+	r.POST("/unsubscribe/:token", handleUnsubscribe)
 }
 
 func getPublicPlans(c *gin.Context) {
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RoleOwner    = "owner"
+	RoleOperator = "operator"
+	RoleReadOnly = "readonly"
+	RoleBilling  = "billing"
+)
+
+const (
+	permPlansRead         = "plans:read"
+	permPlansWrite        = "plans:write"
+	permKeysRead          = "keys:read"
+	permKeysWrite         = "keys:write"
+	permSMTPRead          = "smtp:read"
+	permSMTPWrite         = "smtp:write"
+	permStatsRead         = "stats:read"
+	permLogsRead          = "logs:read"
+	permDeadLettersRead   = "dead-letters:read"
+	permDeadLettersWrite  = "dead-letters:write"
+	permTemplatesRead     = "templates:read"
+	permTemplatesWrite    = "templates:write"
+	permEventsRead        = "events:read"
+	permWebhooksRead      = "webhooks:read"
+	permWebhooksWrite     = "webhooks:write"
+	permSuppressionsRead  = "suppressions:read"
+	permSuppressionsWrite = "suppressions:write"
+	permAdminManage       = "admin:manage"
+)
+
+var rolePermissions = map[string][]string{
+	RoleOwner: {
+		permPlansRead, permPlansWrite, permKeysRead, permKeysWrite, permSMTPRead, permSMTPWrite,
+		permStatsRead, permLogsRead, permDeadLettersRead, permDeadLettersWrite,
+		permTemplatesRead, permTemplatesWrite, permEventsRead, permWebhooksRead, permWebhooksWrite,
+		permSuppressionsRead, permSuppressionsWrite,
+		permAdminManage,
+	},
+	RoleOperator: {
+		permPlansRead, permPlansWrite, permKeysRead, permKeysWrite, permSMTPRead, permSMTPWrite,
+		permStatsRead, permLogsRead, permDeadLettersRead, permDeadLettersWrite,
+		permTemplatesRead, permTemplatesWrite, permEventsRead, permWebhooksRead, permWebhooksWrite,
+		permSuppressionsRead, permSuppressionsWrite,
+	},
+	RoleReadOnly: {
+		permPlansRead, permKeysRead, permSMTPRead, permStatsRead, permLogsRead,
+		permDeadLettersRead, permTemplatesRead, permEventsRead, permWebhooksRead, permSuppressionsRead,
+	},
+	RoleBilling: {
+		permPlansRead, permKeysRead, permKeysWrite, permStatsRead, permEventsRead,
+	},
+}
+
+func validRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func roleAllows(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func requirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if roleAllows(c.GetString("admin_role"), permission) {
+			c.Next()
+			return
+		}
+
+		if strings.HasPrefix(c.Request.URL.Path, "/admin/api/") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+		} else {
+			c.String(http.StatusForbidden, "权限不足")
+		}
+		c.Abort()
+	}
+}
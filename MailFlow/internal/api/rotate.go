@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/auth"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/keys"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+type RotateKeyRequest struct {
+	GraceSeconds *int `json:"grace_seconds"`
+}
+
+func (r *RotateKeyRequest) grace(limit time.Duration) time.Duration {
+	if r.GraceSeconds == nil {
+		return keyRotationGrace
+	}
+
+	grace := time.Duration(max(*r.GraceSeconds, 0)) * time.Second
+	if limit > 0 && grace > limit {
+		return limit
+	}
+	return grace
+}
+
+func rotateAPIKey(c *gin.Context) {
+	var req RotateKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	var key models.APIKey
+	if err := database.DB.First(&key, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API Key不存在"})
+		return
+	}
+
+	rotateKey(c, &key, req.grace(0))
+}
+
+func rotateMyAPIKey(c *gin.Context) {
+	var req RotateKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	value, _ := c.Get("api_key")
+	if value.(*auth.CachedAPIKey).DeprecatedUntil != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "请使用当前有效的API Key进行轮换"})
+		return
+	}
+
+	var key models.APIKey
+	if err := database.DB.First(&key, value.(*auth.CachedAPIKey).ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API Key不存在"})
+		return
+	}
+
+	rotateKey(c, &key, req.grace(keyRotationGrace))
+}
+
+func rotateKey(c *gin.Context, key *models.APIKey, grace time.Duration) {
+	secret, err := keys.Generate()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成API Key失败"})
+		return
+	}
+
+	now := time.Now()
+	revoked := key.PreviousKeyHash
+	current := key.KeyHash
+
+	key.PreviousKeyHash = ""
+	key.PreviousKeyExpiresAt = nil
+	if grace > 0 {
+		expiresAt := now.Add(grace)
+		key.PreviousKeyHash = current
+		key.PreviousKeyExpiresAt = &expiresAt
+	}
+	key.KeyHash = secret.Hash
+	key.KeyPrefix = secret.Prefix
+	key.KeyLast4 = secret.Last4
+	key.RotatedAt = &now
+
+	if err := database.DB.Model(key).Updates(map[string]interface{}{
+		"key_hash":                key.KeyHash,
+		"key_prefix":              key.KeyPrefix,
+		"key_last4":               key.KeyLast4,
+		"previous_key_hash":       key.PreviousKeyHash,
+		"previous_key_expires_at": key.PreviousKeyExpiresAt,
+		"rotated_at":              key.RotatedAt,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "轮换失败"})
+		return
+	}
+
+	auth.InvalidateAPIKeyCache(c.Request.Context(), current, revoked)
+
+	c.JSON(http.StatusOK, RevealedAPIKey{*key, secret.Plain})
+}
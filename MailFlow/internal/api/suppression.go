@@ -0,0 +1,256 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"github.com/mailflow/smtp-loadbalancer/internal/suppression"
+	"gorm.io/gorm"
+)
+
+type SuppressionRequest struct {
+	APIKeyID  uint    `json:"api_key_id"`
+	Address   string  `json:"address" binding:"required"`
+	Reason    string  `json:"reason"`
+	Detail    string  `json:"detail"`
+	ExpiresAt *string `json:"expires_at"`
+}
+
+type SuppressionImportRequest struct {
+	APIKeyID  uint     `json:"api_key_id"`
+	Addresses []string `json:"addresses" binding:"required"`
+	Reason    string   `json:"reason"`
+	Detail    string   `json:"detail"`
+	ExpiresAt *string  `json:"expires_at"`
+}
+
+func listMySuppressions(c *gin.Context) {
+	apiKeyID, _ := c.Get("api_key_id")
+	respondSuppressions(c, database.DB.Model(&models.Suppression{}).Where("api_key_id = ?", apiKeyID))
+}
+
+func createMySuppression(c *gin.Context) {
+	apiKeyID, _ := c.Get("api_key_id")
+
+	var req SuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	saveSuppression(c, apiKeyID.(uint), &req, suppression.SourceAPI)
+}
+
+func deleteMySuppression(c *gin.Context) {
+	apiKeyID, _ := c.Get("api_key_id")
+	removeSuppression(c, database.DB.Where("api_key_id = ?", apiKeyID))
+}
+
+func importMySuppressions(c *gin.Context) {
+	apiKeyID, _ := c.Get("api_key_id")
+
+	var req SuppressionImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	importSuppressionList(c, apiKeyID.(uint), &req)
+}
+
+func listSuppressions(c *gin.Context) {
+	query := database.DB.Model(&models.Suppression{})
+	if keyID := c.Query("key_id"); keyID != "" {
+		query = query.Where("api_key_id = ?", keyID)
+	}
+	respondSuppressions(c, query)
+}
+
+func createSuppression(c *gin.Context) {
+	var req SuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	if !suppressionKeyExists(c, req.APIKeyID) {
+		return
+	}
+
+	saveSuppression(c, req.APIKeyID, &req, suppression.SourceAdmin)
+}
+
+func deleteSuppression(c *gin.Context) {
+	removeSuppression(c, database.DB)
+}
+
+func importSuppressions(c *gin.Context) {
+	var req SuppressionImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	if !suppressionKeyExists(c, req.APIKeyID) {
+		return
+	}
+
+	importSuppressionList(c, req.APIKeyID, &req)
+}
+
+func saveSuppression(c *gin.Context, apiKeyID uint, req *SuppressionRequest, source string) {
+	entries, invalid, err := buildSuppressions(apiKeyID, []string{req.Address}, req.Reason, source, req.Detail, req.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(invalid) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的邮箱地址或域名: " + invalid[0]})
+		return
+	}
+
+	if err := suppression.Save(entries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+		return
+	}
+
+	var entry models.Suppression
+	database.DB.Where("api_key_id = ? AND address = ?", apiKeyID, entries[0].Address).First(&entry)
+	c.JSON(http.StatusOK, entry)
+}
+
+func importSuppressionList(c *gin.Context, apiKeyID uint, req *SuppressionImportRequest) {
+	if len(req.Addresses) > suppression.MaxImportSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("单次最多导入%d条", suppression.MaxImportSize)})
+		return
+	}
+
+	entries, invalid, err := buildSuppressions(apiKeyID, req.Addresses, req.Reason, suppression.SourceImport, req.Detail, req.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := suppression.Save(entries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导入失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("成功导入%d条", len(entries)),
+		"imported": len(entries),
+		"invalid":  invalid,
+	})
+}
+
+func removeSuppression(c *gin.Context, scope *gorm.DB) {
+	result := scope.Delete(&models.Suppression{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "记录不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func buildSuppressions(apiKeyID uint, addresses []string, reason, source, detail string, expiresAt *string) ([]models.Suppression, []string, error) {
+	if reason == "" {
+		reason = suppression.ReasonManual
+	}
+	if !suppression.ValidReason(reason) {
+		return nil, nil, fmt.Errorf("无效的原因: %s", reason)
+	}
+
+	expires, err := parseOptionalTime("expires_at", expiresAt, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if expires != nil && !expires.After(time.Now()) {
+		return nil, nil, fmt.Errorf("expires_at必须晚于当前时间")
+	}
+
+	seen := make(map[string]bool)
+	entries := make([]models.Suppression, 0, len(addresses))
+	invalid := []string{}
+	for _, value := range addresses {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		address, kind, err := suppression.Normalize(value)
+		if err != nil {
+			invalid = append(invalid, value)
+			continue
+		}
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		entries = append(entries, models.Suppression{
+			APIKeyID:  apiKeyID,
+			Address:   address,
+			Type:      kind,
+			Reason:    reason,
+			Source:    source,
+			Detail:    detail,
+			ExpiresAt: expires,
+		})
+	}
+	return entries, invalid, nil
+}
+
+func suppressionKeyExists(c *gin.Context, apiKeyID uint) bool {
+	if apiKeyID == suppression.Global {
+		return true
+	}
+	if err := database.DB.First(&models.APIKey{}, apiKeyID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API Key不存在"})
+		return false
+	}
+	return true
+}
+
+func respondSuppressions(c *gin.Context, query *gorm.DB) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 50
+	}
+
+	if search := strings.ToLower(strings.TrimSpace(c.Query("search"))); search != "" {
+		query = query.Where("address LIKE ?", "%"+search+"%")
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+	if kind := c.Query("type"); kind != "" {
+		query = query.Where("type = ?", kind)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var entries []models.Suppression
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"data":      entries,
+	})
+}
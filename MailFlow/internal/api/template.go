@@ -0,0 +1,207 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"github.com/mailflow/smtp-loadbalancer/internal/render"
+)
+
+type TemplateRequest struct {
+	APIKeyID *uint  `json:"api_key_id"`
+	Name     string `json:"name" binding:"required"`
+	Subject  string `json:"subject" binding:"required"`
+	HTML     string `json:"html"`
+	Text     string `json:"text"`
+}
+
+func (r *TemplateRequest) apply(tpl *models.Template) error {
+	tpl.Name = r.Name
+	tpl.Subject = r.Subject
+	tpl.HTML = r.HTML
+	tpl.Text = r.Text
+	return render.Validate(tpl)
+}
+
+func listTemplates(c *gin.Context) {
+	query := database.DB.Model(&models.Template{})
+
+	switch keyID := c.Query("key_id"); keyID {
+	case "":
+	case "global":
+		query = query.Where("api_key_id IS NULL")
+	default:
+		query = query.Where("api_key_id = ?", keyID)
+	}
+
+	var templates []models.Template
+	if err := query.Order("created_at DESC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+func getTemplate(c *gin.Context) {
+	var tpl models.Template
+	if err := database.DB.First(&tpl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "模板不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, tpl)
+}
+
+func createTemplate(c *gin.Context) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if req.APIKeyID != nil {
+		var key models.APIKey
+		if err := database.DB.First(&key, *req.APIKeyID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "API Key不存在"})
+			return
+		}
+	}
+
+	tpl := models.Template{APIKeyID: req.APIKeyID}
+	if err := req.apply(&tpl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&tpl).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tpl)
+}
+
+func updateTemplate(c *gin.Context) {
+	var tpl models.Template
+	if err := database.DB.First(&tpl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "模板不存在"})
+		return
+	}
+
+	saveTemplate(c, &tpl)
+}
+
+func deleteTemplate(c *gin.Context) {
+	if err := database.DB.Delete(&models.Template{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func listMyTemplates(c *gin.Context) {
+	apiKeyID, _ := c.Get("api_key_id")
+
+	var templates []models.Template
+	if err := database.DB.Where("api_key_id = ? OR api_key_id IS NULL", apiKeyID).Order("created_at DESC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+func getMyTemplate(c *gin.Context) {
+	apiKeyID, _ := c.Get("api_key_id")
+
+	var tpl models.Template
+	if err := database.DB.Where("id = ? AND (api_key_id = ? OR api_key_id IS NULL)", c.Param("id"), apiKeyID).First(&tpl).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "模板不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, tpl)
+}
+
+func createMyTemplate(c *gin.Context) {
+	apiKeyID, _ := c.Get("api_key_id")
+
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	keyID := apiKeyID.(uint)
+	tpl := models.Template{APIKeyID: &keyID}
+	if err := req.apply(&tpl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&tpl).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tpl)
+}
+
+func updateMyTemplate(c *gin.Context) {
+	tpl, ok := findOwnTemplate(c)
+	if !ok {
+		return
+	}
+
+	saveTemplate(c, tpl)
+}
+
+func deleteMyTemplate(c *gin.Context) {
+	tpl, ok := findOwnTemplate(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(tpl).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func findOwnTemplate(c *gin.Context) (*models.Template, bool) {
+	apiKeyID, _ := c.Get("api_key_id")
+
+	var tpl models.Template
+	if err := database.DB.Where("id = ? AND (api_key_id = ? OR api_key_id IS NULL)", c.Param("id"), apiKeyID).First(&tpl).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "模板不存在"})
+		return nil, false
+	}
+	if tpl.APIKeyID == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "全局模板只能由管理员修改"})
+		return nil, false
+	}
+
+	return &tpl, true
+}
+
+func saveTemplate(c *gin.Context, tpl *models.Template) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if err := req.apply(tpl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Save(tpl).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tpl)
+}
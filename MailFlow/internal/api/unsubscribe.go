@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/unsubscribe"
+)
+
+func unsubscribePage(c *gin.Context) {
+	claims, err := unsubscribe.Parse(c.Param("token"))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "unsubscribe.html", gin.H{"error": err.Error()})
+		return
+	}
+
+	c.HTML(http.StatusOK, "unsubscribe.html", gin.H{
+		"email": claims.Email,
+		"token": c.Param("token"),
+	})
+}
+
+func handleUnsubscribe(c *gin.Context) {
+	claims, err := unsubscribe.Parse(c.Param("token"))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "unsubscribe.html", gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := unsubscribe.Record(c.Request.Context(), claims); err != nil {
+		c.HTML(http.StatusNotFound, "unsubscribe.html", gin.H{"error": err.Error()})
+		return
+	}
+
+	c.HTML(http.StatusOK, "unsubscribe.html", gin.H{
+		"email": claims.Email,
+		"done":  true,
+	})
+}
@@ -13,7 +13,8 @@ func RegisterWebUI(r *gin.Engine, cfg *config.Config) {
 
 	r.GET("/", indexPage)
 	r.GET("/admin/login", loginPage)
-	r.POST("/admin/login", HandleLogin(cfg))
+	r.POST("/admin/login", HandleLogin())
+	r.POST("/admin/login/totp", HandleLoginTOTP())
 	r.GET("/admin/logout", HandleLogout())
 
 	admin := r.Group("/admin")
@@ -23,9 +24,15 @@ func RegisterWebUI(r *gin.Engine, cfg *config.Config) {
 		admin.GET("/", dashboard)
 		admin.GET("/keys", keysPage)
 		admin.GET("/smtp", smtpPage)
+		admin.GET("/dkim", dkimPage)
 		admin.GET("/plans", plansPage)
-		admin.GET("/admin-tokens", adminTokensPage)
+		admin.GET("/admin-tokens", requirePermission(permAdminManage), adminTokensPage)
+		admin.GET("/users", adminUsersPage)
 		admin.GET("/logs", logsPage)
+		admin.GET("/dead-letters", deadLettersPage)
+		admin.GET("/templates", templatesPage)
+		admin.GET("/webhooks", webhooksPage)
+		admin.GET("/suppressions", suppressionsPage)
 		admin.GET("/stats", statsPage)
 	}
 }
@@ -59,6 +66,13 @@ func smtpPage(c *gin.Context) {
 	})
 }
 
+func dkimPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "dkim.html", gin.H{
+		"title": "DKIM",
+		"page":  "dkim",
+	})
+}
+
 func logsPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "logs.html", gin.H{
 		"title": "发送日志",
@@ -66,6 +80,34 @@ func logsPage(c *gin.Context) {
 	})
 }
 
+func deadLettersPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "dead-letters.html", gin.H{
+		"title": "死信队列",
+		"page":  "dead-letters",
+	})
+}
+
+func templatesPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "templates.html", gin.H{
+		"title": "邮件模板",
+		"page":  "templates",
+	})
+}
+
+func webhooksPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "webhooks.html", gin.H{
+		"title": "Webhook",
+		"page":  "webhooks",
+	})
+}
+
+func suppressionsPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "suppressions.html", gin.H{
+		"title": "抑制列表",
+		"page":  "suppressions",
+	})
+}
+
 func statsPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "stats.html", gin.H{
 		"title": "统计报表",
@@ -80,6 +122,13 @@ func plansPage(c *gin.Context) {
 	})
 }
 
+func adminUsersPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "admin-users.html", gin.H{
+		"title": "管理员",
+		"page":  "users",
+	})
+}
+
 func adminTokensPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "admin-tokens.html", gin.H{
 		"title": "Token",
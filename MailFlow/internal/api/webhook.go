@@ -0,0 +1,390 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"github.com/mailflow/smtp-loadbalancer/internal/webhook"
+	"gorm.io/gorm"
+)
+
+type WebhookRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+	IsActive    *bool    `json:"is_active"`
+}
+
+type RevealedWebhook struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+func (r *WebhookRequest) apply(hook *models.Webhook) error {
+	if err := webhook.ValidateURL(r.URL); err != nil {
+		return err
+	}
+	eventTypes, err := webhook.NormalizeEvents(r.Events, webhook.AdminOwned(hook))
+	if err != nil {
+		return err
+	}
+
+	hook.URL = strings.TrimSpace(r.URL)
+	hook.Events = eventTypes
+	hook.Description = r.Description
+	if r.IsActive != nil {
+		hook.IsActive = *r.IsActive
+	}
+	return nil
+}
+
+func listMyWebhooks(c *gin.Context) {
+	apiKeyID, _ := c.Get("api_key_id")
+
+	var hooks []models.Webhook
+	if err := database.DB.Where("api_key_id = ?", apiKeyID).Order("created_at DESC").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+func createMyWebhook(c *gin.Context) {
+	apiKeyID, _ := c.Get("api_key_id")
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.Webhook{}).Where("api_key_id = ?", apiKeyID).Count(&count)
+	if count >= webhook.MaxWebhooksPerKey {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook数量已达上限"})
+		return
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hook := models.Webhook{APIKeyID: apiKeyID.(uint), Secret: secret, IsActive: true}
+	if err := req.apply(&hook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RevealedWebhook{Webhook: hook, Secret: secret})
+}
+
+func updateMyWebhook(c *gin.Context) {
+	hook, ok := findMyWebhook(c)
+	if !ok {
+		return
+	}
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	if err := req.apply(hook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Save(hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+func deleteMyWebhook(c *gin.Context) {
+	hook, ok := findMyWebhook(c)
+	if !ok {
+		return
+	}
+
+	if err := deleteWebhookRecords(hook.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func rotateMyWebhookSecret(c *gin.Context) {
+	hook, ok := findMyWebhook(c)
+	if !ok {
+		return
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.DB.Model(hook).Update("secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RevealedWebhook{Webhook: *hook, Secret: secret})
+}
+
+func testMyWebhook(c *gin.Context) {
+	hook, ok := findMyWebhook(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := webhook.SendTest(hook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建测试事件失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "测试事件已加入投递队列", "delivery": delivery})
+}
+
+func listMyWebhookDeliveries(c *gin.Context) {
+	hook, ok := findMyWebhook(c)
+	if !ok {
+		return
+	}
+
+	respondDeliveries(c, database.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", hook.ID))
+}
+
+func findMyWebhook(c *gin.Context) (*models.Webhook, bool) {
+	apiKeyID, _ := c.Get("api_key_id")
+
+	var hook models.Webhook
+	if err := database.DB.Where("id = ? AND api_key_id = ?", c.Param("id"), apiKeyID).First(&hook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook不存在"})
+		return nil, false
+	}
+	return &hook, true
+}
+
+func listWebhooks(c *gin.Context) {
+	query := database.DB.Model(&models.Webhook{})
+	if keyID := c.Query("key_id"); keyID != "" {
+		query = query.Where("api_key_id = ?", keyID)
+	}
+
+	var hooks []models.Webhook
+	if err := query.Order("created_at DESC").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+func createWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.Webhook{}).Where("api_key_id = ?", 0).Count(&count)
+	if count >= webhook.MaxWebhooksPerKey {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook数量已达上限"})
+		return
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hook := models.Webhook{Secret: secret, IsActive: true}
+	if err := req.apply(&hook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RevealedWebhook{Webhook: hook, Secret: secret})
+}
+
+func updateWebhook(c *gin.Context) {
+	var hook models.Webhook
+	if err := database.DB.First(&hook, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook不存在"})
+		return
+	}
+	if !webhook.AdminOwned(&hook) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "客户Webhook只能由客户通过API修改"})
+		return
+	}
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	if err := req.apply(&hook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Save(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+func toggleWebhook(c *gin.Context) {
+	var hook models.Webhook
+	if err := database.DB.First(&hook, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook不存在"})
+		return
+	}
+
+	hook.IsActive = !hook.IsActive
+	if err := database.DB.Model(&hook).Update("is_active", hook.IsActive).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+func deleteWebhook(c *gin.Context) {
+	var hook models.Webhook
+	if err := database.DB.First(&hook, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook不存在"})
+		return
+	}
+
+	if err := deleteWebhookRecords(hook.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func listWebhookDeliveries(c *gin.Context) {
+	query := database.DB.Model(&models.WebhookDelivery{})
+	if webhookID := c.Query("webhook_id"); webhookID != "" {
+		query = query.Where("webhook_id = ?", webhookID)
+	}
+	if keyID := c.Query("key_id"); keyID != "" {
+		query = query.Where("api_key_id = ?", keyID)
+	}
+
+	respondDeliveries(c, query)
+}
+
+func getWebhookDelivery(c *gin.Context) {
+	var delivery models.WebhookDelivery
+	if err := database.DB.First(&delivery, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "投递记录不存在"})
+		return
+	}
+
+	var attempts []models.WebhookAttempt
+	database.DB.Where("delivery_id = ?", delivery.ID).Order("attempt ASC").Find(&attempts)
+
+	c.JSON(http.StatusOK, gin.H{
+		"delivery": delivery,
+		"attempts": attempts,
+	})
+}
+
+func retryWebhookDelivery(c *gin.Context) {
+	var delivery models.WebhookDelivery
+	if err := database.DB.First(&delivery, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "投递记录不存在"})
+		return
+	}
+	if delivery.Status == "pending" || delivery.Status == "delivering" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该记录正在等待投递"})
+		return
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&delivery).Updates(map[string]interface{}{
+		"status":          "pending",
+		"next_attempt_at": &now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已加入重新投递队列"})
+}
+
+func respondDeliveries(c *gin.Context, query *gorm.DB) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 50
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if eventType := c.Query("event_type"); eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var deliveries []models.WebhookDelivery
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"data":      deliveries,
+	})
+}
+
+func deleteWebhookRecords(webhookID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("delivery_id IN (?)", tx.Model(&models.WebhookDelivery{}).Select("id").Where("webhook_id = ?", webhookID)).
+			Delete(&models.WebhookAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("webhook_id = ?", webhookID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Webhook{}, webhookID).Error
+	})
+}
@@ -3,29 +3,42 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/keys"
 	"github.com/mailflow/smtp-loadbalancer/internal/models"
 	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/mailflow/smtp-loadbalancer/internal/ratelimit"
 )
 
 const (
-	APIKeyCacheTTL = 5 * time.Minute
+	APIKeyCacheTTL  = 5 * time.Minute
 	RateLimitWindow = 60 * time.Second
 )
 
 type CachedAPIKey struct {
-	ID           uint   `json:"id"`
-	Name         string `json:"name"`
-	MinuteLimit  int    `json:"minute_limit"`
-	DailyLimit   int    `json:"daily_limit"`
-	WeeklyLimit  int    `json:"weekly_limit"`
-	MonthlyLimit int    `json:"monthly_limit"`
-	TotalLimit   int    `json:"total_limit"`
-	Status       string `json:"status"`
+	ID                 uint       `json:"id"`
+	Name               string     `json:"name"`
+	MinuteLimit        int        `json:"minute_limit"`
+	DailyLimit         int        `json:"daily_limit"`
+	WeeklyLimit        int        `json:"weekly_limit"`
+	MonthlyLimit       int        `json:"monthly_limit"`
+	TotalLimit         int        `json:"total_limit"`
+	RateLimitAlgorithm string     `json:"rate_limit_algorithm"`
+	BurstLimit         int        `json:"burst_limit"`
+	Status             string     `json:"status"`
+	StartsAt           *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	AllowedIPs         string     `json:"allowed_ips,omitempty"`
+	AllowedOrigins     string     `json:"allowed_origins,omitempty"`
+	Scopes             string     `json:"scopes,omitempty"`
+	DeprecatedUntil    *time.Time `json:"deprecated_until,omitempty"`
 }
 
 func AuthMiddleware() gin.HandlerFunc {
@@ -44,8 +57,33 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		if key.Status != "active" {
-			c.JSON(403, gin.H{"error": "API Key已被禁用"})
+		if err := checkValidity(key, time.Now()); err != nil {
+			c.JSON(403, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		if !ipAllowed(key.AllowedIPs, c.ClientIP()) {
+			c.JSON(403, gin.H{"error": fmt.Sprintf("IP地址 %s 不在允许列表中", c.ClientIP())})
+			c.Abort()
+			return
+		}
+
+		if !originAllowed(key.AllowedOrigins, c) {
+			c.JSON(403, gin.H{"error": "请求来源不在允许列表中"})
+			c.Abort()
+			return
+		}
+
+		limit, err := ratelimit.Peek(c.Request.Context(), key.ID, key.RateLimitAlgorithm, key.MinuteLimit, key.BurstLimit, 1)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		SetRateLimitHeaders(c, limit)
+		if !limit.Allowed {
+			c.JSON(429, gin.H{"error": fmt.Sprintf("超过每分钟限制: %d，请在%d秒后重试", key.MinuteLimit, retryAfterSeconds(limit))})
 			c.Abort()
 			return
 		}
@@ -56,52 +94,105 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if key.DeprecatedUntil != nil {
+			c.Header("X-API-Key-Deprecated", key.DeprecatedUntil.Format(time.RFC3339))
+		}
+
 		c.Set("api_key_id", key.ID)
 		c.Set("api_key_name", key.Name)
+		c.Set("api_key", key)
 		c.Next()
 	}
 }
 
+func SetRateLimitHeaders(c *gin.Context, limit *ratelimit.Result) {
+	if limit.Limit <= 0 {
+		return
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(limit.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(limit.ResetAt().Unix(), 10))
+	if !limit.Allowed {
+		c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(limit)))
+	}
+}
+
+func retryAfterSeconds(limit *ratelimit.Result) int {
+	return int(math.Ceil(limit.RetryAfter.Seconds()))
+}
+
 func validateAPIKey(ctx context.Context, apiKey string) (*CachedAPIKey, error) {
-	cacheKey := fmt.Sprintf("mailflow:apikey:%s", apiKey)
+	keyHash := keys.Hash(apiKey)
+	cacheKey := fmt.Sprintf("mailflow:apikey:%s", keyHash)
 	
 	val, err := queue.Client.Get(ctx, cacheKey).Result()
 	if err == nil {
 		var cached CachedAPIKey
 		if err := json.Unmarshal([]byte(val), &cached); err == nil {
+			if cached.DeprecatedUntil != nil && time.Now().After(*cached.DeprecatedUntil) {
+				return nil, fmt.Errorf("无效的API Key")
+			}
 			return &cached, nil
 		}
 	}
 
 	var key models.APIKey
-	if err := database.DB.Where("key = ?", apiKey).First(&key).Error; err != nil {
+	now := time.Now()
+	if err := database.DB.Where("key_hash = ? OR (previous_key_hash = ? AND previous_key_expires_at > ?)", keyHash, keyHash, now).First(&key).Error; err != nil {
 		return nil, fmt.Errorf("无效的API Key")
 	}
 
 	cached := &CachedAPIKey{
-		ID:           key.ID,
-		Name:         key.Name,
-		MinuteLimit:  key.MinuteLimit,
-		DailyLimit:   key.DailyLimit,
-		WeeklyLimit:  key.WeeklyLimit,
-		MonthlyLimit: key.MonthlyLimit,
-		TotalLimit:   key.TotalLimit,
-		Status:       key.Status,
+		ID:                 key.ID,
+		Name:               key.Name,
+		MinuteLimit:        key.MinuteLimit,
+		DailyLimit:         key.DailyLimit,
+		WeeklyLimit:        key.WeeklyLimit,
+		MonthlyLimit:       key.MonthlyLimit,
+		TotalLimit:         key.TotalLimit,
+		RateLimitAlgorithm: key.RateLimitAlgorithm,
+		BurstLimit:         key.BurstLimit,
+		Status:             key.Status,
+		StartsAt:           key.StartsAt,
+		ExpiresAt:          key.ExpiresAt,
+		AllowedIPs:         key.AllowedIPs,
+		AllowedOrigins:     key.AllowedOrigins,
+		Scopes:             key.Scopes,
+	}
+
+	ttl := APIKeyCacheTTL
+	if key.KeyHash != keyHash {
+		cached.DeprecatedUntil = key.PreviousKeyExpiresAt
+		ttl = min(ttl, key.PreviousKeyExpiresAt.Sub(now))
 	}
 
 	data, _ := json.Marshal(cached)
-	queue.Client.Set(ctx, cacheKey, data, APIKeyCacheTTL)
+	queue.Client.Set(ctx, cacheKey, data, ttl)
 
 	return cached, nil
 }
 
+func checkValidity(key *CachedAPIKey, now time.Time) error {
+	if key.Status == "expired" || (key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+		return errors.New("API Key已过期")
+	}
+	if key.Status != "active" {
+		return errors.New("API Key已被禁用")
+	}
+	if key.StartsAt != nil && now.Before(*key.StartsAt) {
+		return fmt.Errorf("API Key将于%s生效", key.StartsAt.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
 func checkRateLimit(ctx context.Context, key *CachedAPIKey) error {
 	can, msg, err := PreCheckQuota(ctx, key)
 	if err != nil {
 		return err
 	}
 	if !can {
-		return fmt.Errorf(msg)
+		return errors.New(msg)
 	}
 	return nil
 }
@@ -109,14 +200,6 @@ func checkRateLimit(ctx context.Context, key *CachedAPIKey) error {
 func PreCheckQuota(ctx context.Context, key *CachedAPIKey) (bool, string, error) {
 	now := time.Now()
 	
-	if key.MinuteLimit > 0 {
-		minuteKey := fmt.Sprintf("mailflow:minute:%d", key.ID)
-		count, _ := queue.Client.Get(ctx, minuteKey).Int64()
-		if count >= int64(key.MinuteLimit) {
-			return false, fmt.Sprintf("超过每分钟限制: %d，将在1分钟后恢复", key.MinuteLimit), nil
-		}
-	}
-
 	if key.DailyLimit > 0 {
 		dailyKey := fmt.Sprintf("mailflow:daily:%d:%s", key.ID, now.Format("2006-01-02"))
 		count, _ := queue.Client.Get(ctx, dailyKey).Int64()
@@ -157,14 +240,8 @@ func PreCheckQuota(ctx context.Context, key *CachedAPIKey) (bool, string, error)
 func ConsumeQuota(ctx context.Context, apiKeyID uint) error {
 	now := time.Now()
 	
-	minuteKey := fmt.Sprintf("mailflow:minute:%d", apiKeyID)
-	count, _ := queue.Client.Incr(ctx, minuteKey).Result()
-	if count == 1 {
-		queue.Client.Expire(ctx, minuteKey, 60*time.Second)
-	}
-	
 	dailyKey := fmt.Sprintf("mailflow:daily:%d:%s", apiKeyID, now.Format("2006-01-02"))
-	count, _ = queue.Client.Incr(ctx, dailyKey).Result()
+	count, _ := queue.Client.Incr(ctx, dailyKey).Result()
 	if count == 1 {
 		queue.Client.Expire(ctx, dailyKey, 48*time.Hour)
 	}
@@ -198,14 +275,16 @@ func GetRemainingQuota(ctx context.Context, apiKeyID uint) (map[string]interface
 	result := make(map[string]interface{})
 	
 	if key.MinuteLimit > 0 {
-		minuteKey := fmt.Sprintf("mailflow:minute:%d", apiKeyID)
-		used, _ := queue.Client.Get(ctx, minuteKey).Int64()
-		ttl, _ := queue.Client.TTL(ctx, minuteKey).Result()
+		limit, err := ratelimit.Peek(ctx, apiKeyID, key.RateLimitAlgorithm, key.MinuteLimit, key.BurstLimit, 0)
+		if err != nil {
+			return nil, err
+		}
 		result["minute"] = map[string]interface{}{
-			"limit":     key.MinuteLimit,
-			"used":      used,
-			"remaining": int64(key.MinuteLimit) - used,
-			"reset_in":  int(ttl.Seconds()),
+			"limit":     limit.Limit,
+			"used":      limit.Limit - limit.Remaining,
+			"remaining": limit.Remaining,
+			"reset_in":  int(limit.Reset.Seconds()),
+			"algorithm": key.RateLimitAlgorithm,
 		}
 	}
 	
@@ -215,11 +294,11 @@ func GetRemainingQuota(ctx context.Context, apiKeyID uint) (map[string]interface
 		tomorrow := now.Add(24 * time.Hour)
 		resetTime := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, tomorrow.Location())
 		result["daily"] = map[string]interface{}{
-			"limit":      key.DailyLimit,
-			"used":       used,
-			"remaining":  int64(key.DailyLimit) - used,
-			"reset_at":   resetTime.Format("2006-01-02 15:04:05"),
-			"reset_in":   int(resetTime.Sub(now).Seconds()),
+			"limit":     key.DailyLimit,
+			"used":      used,
+			"remaining": int64(key.DailyLimit) - used,
+			"reset_at":  resetTime.Format("2006-01-02 15:04:05"),
+			"reset_in":  int(resetTime.Sub(now).Seconds()),
 		}
 	}
 	
@@ -261,8 +340,7 @@ func ResetAPIKeyQuota(ctx context.Context, apiKeyID uint, quotaType string) erro
 	
 	switch quotaType {
 	case "minute":
-		minuteKey := fmt.Sprintf("mailflow:minute:%d", apiKeyID)
-		return queue.Client.Del(ctx, minuteKey).Err()
+		return ratelimit.Reset(ctx, apiKeyID)
 	case "daily":
 		dailyKey := fmt.Sprintf("mailflow:daily:%d:%s", apiKeyID, now.Format("2006-01-02"))
 		return queue.Client.Del(ctx, dailyKey).Err()
@@ -276,13 +354,14 @@ func ResetAPIKeyQuota(ctx context.Context, apiKeyID uint, quotaType string) erro
 		totalKey := fmt.Sprintf("mailflow:total:%d", apiKeyID)
 		return queue.Client.Del(ctx, totalKey).Err()
 	case "all":
-		minuteKey := fmt.Sprintf("mailflow:minute:%d", apiKeyID)
+		if err := ratelimit.Reset(ctx, apiKeyID); err != nil {
+			return err
+		}
 		dailyKey := fmt.Sprintf("mailflow:daily:%d:%s", apiKeyID, now.Format("2006-01-02"))
 		weekKey := fmt.Sprintf("mailflow:week:%d:%s", apiKeyID, now.Format("2006-W%V"))
 		monthKey := fmt.Sprintf("mailflow:month:%d:%s", apiKeyID, now.Format("2006-01"))
 		totalKey := fmt.Sprintf("mailflow:total:%d", apiKeyID)
 		pipe := queue.Client.Pipeline()
-		pipe.Del(ctx, minuteKey)
 		pipe.Del(ctx, dailyKey)
 		pipe.Del(ctx, weekKey)
 		pipe.Del(ctx, monthKey)
@@ -294,8 +373,16 @@ func ResetAPIKeyQuota(ctx context.Context, apiKeyID uint, quotaType string) erro
 	}
 }
 
-func InvalidateAPIKeyCache(ctx context.Context, apiKey string) error {
-	cacheKey := fmt.Sprintf("mailflow:apikey:%s", apiKey)
-	return queue.Client.Del(ctx, cacheKey).Err()
+func InvalidateAPIKeyCache(ctx context.Context, keyHashes ...string) error {
+	cacheKeys := make([]string, 0, len(keyHashes))
+	for _, keyHash := range keyHashes {
+		if keyHash != "" {
+			cacheKeys = append(cacheKeys, fmt.Sprintf("mailflow:apikey:%s", keyHash))
+		}
+	}
+	if len(cacheKeys) == 0 {
+		return nil
+	}
+	return queue.Client.Del(ctx, cacheKeys...).Err()
 }
 
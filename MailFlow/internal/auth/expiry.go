@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/events"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+const ExpirySweepInterval = time.Minute
+
+func StartExpirySweeper(ctx context.Context) {
+	ticker := time.NewTicker(ExpirySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expireAPIKeys(ctx)
+		}
+	}
+}
+
+func expireAPIKeys(ctx context.Context) {
+	now := time.Now()
+
+	var expired []models.APIKey
+	if err := database.DB.Where("status = ? AND expires_at IS NOT NULL AND expires_at <= ?", "active", now).Find(&expired).Error; err != nil {
+		log.Printf("查询过期API Key失败: %v", err)
+		return
+	}
+
+	for _, key := range expired {
+		result := database.DB.Model(&models.APIKey{}).
+			Where("id = ? AND status = ?", key.ID, "active").
+			Update("status", "expired")
+		if result.Error != nil {
+			log.Printf("更新API Key[%d]状态失败: %v", key.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		InvalidateAPIKeyCache(ctx, key.KeyHash, key.PreviousKeyHash)
+
+		if err := events.Publish(ctx, events.KeyExpired, key.ID, map[string]interface{}{
+			"name":       key.Name,
+			"plan":       key.Plan,
+			"expires_at": key.ExpiresAt,
+		}); err != nil {
+			log.Printf("API Key[%d]过期事件发布失败: %v", key.ID, err)
+		}
+
+		log.Printf("API Key[%s]已过期", key.Name)
+	}
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/mailflow/smtp-loadbalancer/internal/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+var reserveScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+for i = 1, #KEYS do
+	local limit = tonumber(ARGV[i + 1])
+	if limit > 0 then
+		local used = tonumber(redis.call('GET', KEYS[i]) or '0')
+		if used + n > limit then
+			return i
+		end
+	end
+end
+for i = 1, #KEYS do
+	redis.call('INCRBY', KEYS[i], n)
+	local ttl = tonumber(ARGV[#KEYS + i + 1])
+	if ttl > 0 and redis.call('TTL', KEYS[i]) == -1 then
+		redis.call('EXPIRE', KEYS[i], ttl)
+	end
+end
+return 0
+`)
+
+var refundScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+for i = 1, #KEYS do
+	local used = tonumber(redis.call('GET', KEYS[i]) or '0')
+	if used > 0 then
+		redis.call('DECRBY', KEYS[i], math.min(used, n))
+	end
+end
+return 0
+`)
+
+func quotaKeys(apiKeyID uint, t time.Time) []string {
+	return []string{
+		fmt.Sprintf("mailflow:daily:%d:%s", apiKeyID, t.Format("2006-01-02")),
+		fmt.Sprintf("mailflow:week:%d:%s", apiKeyID, t.Format("2006-W%V")),
+		fmt.Sprintf("mailflow:month:%d:%s", apiKeyID, t.Format("2006-01")),
+		fmt.Sprintf("mailflow:total:%d", apiKeyID),
+	}
+}
+
+func ReserveQuota(ctx context.Context, key *CachedAPIKey, n int) (bool, string, *ratelimit.Result, error) {
+	if n <= 0 {
+		return true, "", nil, nil
+	}
+
+	now := time.Now()
+	args := []interface{}{
+		n,
+		key.DailyLimit, key.WeeklyLimit, key.MonthlyLimit, key.TotalLimit,
+		int((48 * time.Hour).Seconds()), int((8 * 24 * time.Hour).Seconds()), int((32 * 24 * time.Hour).Seconds()), 0,
+	}
+
+	failed, err := reserveScript.Run(ctx, queue.Client, quotaKeys(key.ID, now), args...).Int()
+	if err != nil {
+		return false, "", nil, fmt.Errorf("预留配额失败: %w", err)
+	}
+
+	switch failed {
+	case 0:
+	case 1:
+		return false, fmt.Sprintf("超过每日限额: %d，本次需要%d个配额", key.DailyLimit, n), nil, nil
+	case 2:
+		return false, fmt.Sprintf("超过每周限额: %d，本次需要%d个配额", key.WeeklyLimit, n), nil, nil
+	case 3:
+		return false, fmt.Sprintf("超过每月限额: %d，本次需要%d个配额", key.MonthlyLimit, n), nil, nil
+	default:
+		return false, fmt.Sprintf("超过总限额: %d，本次需要%d个配额", key.TotalLimit, n), nil, nil
+	}
+
+	limit, err := ratelimit.Take(ctx, key.ID, key.RateLimitAlgorithm, key.MinuteLimit, key.BurstLimit, n)
+	if err != nil || !limit.Allowed {
+		RefundQuota(ctx, key.ID, n, now)
+	}
+	if err != nil {
+		return false, "", nil, err
+	}
+	if !limit.Allowed {
+		return false, fmt.Sprintf("超过每分钟限制: %d，本次需要%d个配额，请在%d秒后重试", key.MinuteLimit, n, retryAfterSeconds(limit)), limit, nil
+	}
+
+	return true, "", limit, nil
+}
+
+func RefundQuota(ctx context.Context, apiKeyID uint, n int, reservedAt time.Time) error {
+	if n <= 0 {
+		return nil
+	}
+
+	return refundScript.Run(ctx, queue.Client, quotaKeys(apiKeyID, reservedAt), n).Err()
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/mailflow/smtp-loadbalancer/internal/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	queue.Client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { queue.Client.Close() })
+	return mr
+}
+
+func usage(t *testing.T, apiKeyID uint) []int {
+	t.Helper()
+	counts := make([]int, 0, 4)
+	for _, key := range quotaKeys(apiKeyID, time.Now()) {
+		value, err := queue.Client.Get(context.Background(), key).Int()
+		if err != nil && err != redis.Nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, value)
+	}
+	return counts
+}
+
+func reserve(t *testing.T, key *CachedAPIKey, n int) (bool, string, *ratelimit.Result) {
+	t.Helper()
+	ok, reason, limit, err := ReserveQuota(context.Background(), key, n)
+	if err != nil {
+		t.Fatalf("ReserveQuota: %v", err)
+	}
+	return ok, reason, limit
+}
+
+func TestReserveQuotaLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    CachedAPIKey
+		reason string
+	}{
+		{"daily", CachedAPIKey{ID: 1, DailyLimit: 5}, "每日限额"},
+		{"weekly", CachedAPIKey{ID: 2, WeeklyLimit: 5}, "每周限额"},
+		{"monthly", CachedAPIKey{ID: 3, MonthlyLimit: 5}, "每月限额"},
+		{"total", CachedAPIKey{ID: 4, TotalLimit: 5}, "总限额"},
+		{"first exhausted limit wins", CachedAPIKey{ID: 5, DailyLimit: 100, WeeklyLimit: 5, TotalLimit: 5}, "每周限额"},
+	}
+
+	for _, tt := range tests {
+		setupRedis(t)
+		key := tt.key
+
+		if ok, reason, _ := reserve(t, &key, 3); !ok {
+			t.Fatalf("%s: first reserve denied: %s", tt.name, reason)
+		}
+		ok, reason, _ := reserve(t, &key, 3)
+		if ok || !strings.Contains(reason, tt.reason) {
+			t.Fatalf("%s: second reserve = %v, %q; want denial mentioning %s", tt.name, ok, reason, tt.reason)
+		}
+		if got := usage(t, key.ID); got[0] != 3 || got[1] != 3 || got[2] != 3 || got[3] != 3 {
+			t.Fatalf("%s: denied reserve must not change any counter: %v", tt.name, got)
+		}
+		if ok, reason, _ := reserve(t, &key, 2); !ok {
+			t.Fatalf("%s: reserve up to the limit denied: %s", tt.name, reason)
+		}
+		if got := usage(t, key.ID); got[0] != 5 || got[3] != 5 {
+			t.Fatalf("%s: usage = %v, want 5 everywhere", tt.name, got)
+		}
+	}
+}
+
+func TestReserveQuotaExpiry(t *testing.T) {
+	mr := setupRedis(t)
+	key := &CachedAPIKey{ID: 1, DailyLimit: 10}
+	reserve(t, key, 1)
+
+	keys := quotaKeys(key.ID, time.Now())
+	want := []time.Duration{48 * time.Hour, 8 * 24 * time.Hour, 32 * 24 * time.Hour, 0}
+	for i, k := range keys {
+		if ttl := mr.TTL(k); ttl != want[i] {
+			t.Errorf("TTL(%s) = %s, want %s", k, ttl, want[i])
+		}
+	}
+}
+
+func TestReserveQuotaRateLimitRefund(t *testing.T) {
+	setupRedis(t)
+	key := &CachedAPIKey{ID: 7, DailyLimit: 100, TotalLimit: 100, MinuteLimit: 2, RateLimitAlgorithm: ratelimit.AlgorithmFixed}
+
+	if ok, reason, _ := reserve(t, key, 2); !ok {
+		t.Fatalf("first reserve denied: %s", reason)
+	}
+
+	ok, reason, limit := reserve(t, key, 1)
+	if ok || limit == nil || limit.Allowed || !strings.Contains(reason, "每分钟限制") {
+		t.Fatalf("reserve over rate limit = %v, %q, %+v", ok, reason, limit)
+	}
+	if got := usage(t, key.ID); got[0] != 2 || got[1] != 2 || got[2] != 2 || got[3] != 2 {
+		t.Fatalf("quota reserved before a rate-limit denial must be refunded: %v", got)
+	}
+}
+
+func TestRefundQuotaNeverNegative(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+	key := &CachedAPIKey{ID: 9, DailyLimit: 10}
+	reserve(t, key, 2)
+
+	if err := RefundQuota(ctx, key.ID, 5, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := usage(t, key.ID); got[0] != 0 || got[1] != 0 || got[2] != 0 || got[3] != 0 {
+		t.Fatalf("refund larger than usage = %v, want zeros", got)
+	}
+
+	if err := RefundQuota(ctx, 42, 3, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range quotaKeys(42, time.Now()) {
+		if mr.Exists(k) {
+			t.Fatalf("refund for an unused key must not create %s", k)
+		}
+	}
+
+	if err := RefundQuota(ctx, key.ID, 0, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReserveQuotaNothing(t *testing.T) {
+	setupRedis(t)
+	ok, _, limit, err := ReserveQuota(context.Background(), &CachedAPIKey{ID: 1, DailyLimit: 1}, 0)
+	if !ok || limit != nil || err != nil {
+		t.Fatalf("ReserveQuota(0) = %v, %+v, %v", ok, limit, err)
+	}
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+}
+
+func parseCIDR(value string) (*net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("无效的IP地址: %s", value)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("无效的CIDR: %s", value)
+	}
+	return network, nil
+}
+
+func NormalizeIPAllowlist(value string) (string, error) {
+	entries := splitList(value)
+	normalized := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		network, err := parseCIDR(entry)
+		if err != nil {
+			return "", err
+		}
+		normalized = append(normalized, network.String())
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+func NormalizeOrigins(value string) (string, error) {
+	entries := splitList(value)
+	normalized := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		origin := parseOrigin(entry)
+		if origin == "" {
+			return "", fmt.Errorf("无效的来源: %s", entry)
+		}
+		normalized = append(normalized, origin)
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+func parseOrigin(value string) string {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+func ipAllowed(allowlist, clientIP string) bool {
+	if allowlist == "" {
+		return true
+	}
+
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, entry := range splitList(allowlist) {
+		network, err := parseCIDR(entry)
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func originAllowed(allowlist string, c *gin.Context) bool {
+	if allowlist == "" {
+		return true
+	}
+
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		origin = c.GetHeader("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+
+	origin = parseOrigin(origin)
+	for _, allowed := range splitList(allowlist) {
+		if origin != "" && origin == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizeIPAllowlist(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"203.0.113.7", "203.0.113.7/32", false},
+		{"10.0.0.5/24", "10.0.0.0/24", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"203.0.113.7, 10.0.0.0/8;\n2001:db8::/32", "203.0.113.7/32,10.0.0.0/8,2001:db8::/32", false},
+		{"203.0.113.256", "", true},
+		{"10.0.0.0/33", "", true},
+		{"example.com", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeIPAllowlist(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeIPAllowlist(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeIPAllowlist(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIPAllowed(t *testing.T) {
+	allowlist := "203.0.113.7/32,10.0.0.0/8,2001:db8::/32"
+	tests := []struct {
+		allowlist string
+		ip        string
+		want      bool
+	}{
+		{"", "198.51.100.1", true},
+		{allowlist, "203.0.113.7", true},
+		{allowlist, "203.0.113.8", false},
+		{allowlist, "10.200.3.4", true},
+		{allowlist, "::ffff:10.1.2.3", true},
+		{allowlist, "2001:db8:1::5", true},
+		{allowlist, "2001:db9::5", false},
+		{allowlist, "", false},
+		{allowlist, "not-an-ip", false},
+	}
+
+	for _, tt := range tests {
+		if got := ipAllowed(tt.allowlist, tt.ip); got != tt.want {
+			t.Errorf("ipAllowed(%q, %q) = %v, want %v", tt.allowlist, tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeOrigins(t *testing.T) {
+	got, err := NormalizeOrigins("https://App.Example.com/path, http://localhost:3000")
+	if err != nil || got != "https://app.example.com,http://localhost:3000" {
+		t.Fatalf("NormalizeOrigins = %q, %v", got, err)
+	}
+	for _, value := range []string{"app.example.com", "ftp://example.com", "https://"} {
+		if _, err := NormalizeOrigins(value); err == nil {
+			t.Errorf("NormalizeOrigins(%q) should fail", value)
+		}
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	allowlist := "https://app.example.com"
+	tests := []struct {
+		allowlist string
+		header    string
+		value     string
+		want      bool
+	}{
+		{"", "Origin", "https://evil.example.net", true},
+		{allowlist, "", "", true},
+		{allowlist, "Origin", "https://app.example.com", true},
+		{allowlist, "Origin", "https://APP.example.com", true},
+		{allowlist, "Origin", "https://evil.example.net", false},
+		{allowlist, "Origin", "http://app.example.com", false},
+		{allowlist, "Referer", "https://app.example.com/settings", true},
+		{allowlist, "Referer", "https://evil.example.net/app.example.com", false},
+	}
+
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/api/v1/send", nil)
+		if tt.header != "" {
+			c.Request.Header.Set(tt.header, tt.value)
+		}
+		if got := originAllowed(tt.allowlist, c); got != tt.want {
+			t.Errorf("originAllowed(%q, %s: %q) = %v, want %v", tt.allowlist, tt.header, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ScopeSend              = "send"
+	ScopeMessagesRead      = "messages:read"
+	ScopeLogsRead          = "logs:read"
+	ScopeUsageRead         = "usage:read"
+	ScopeIdentitiesRead    = "identities:read"
+	ScopeTemplatesRead     = "templates:read"
+	ScopeTemplatesWrite    = "templates:write"
+	ScopeKeyRotate         = "key:rotate"
+	ScopeWebhooksRead      = "webhooks:read"
+	ScopeWebhooksWrite     = "webhooks:write"
+	ScopeSuppressionsRead  = "suppressions:read"
+	ScopeSuppressionsWrite = "suppressions:write"
+)
+
+var Scopes = []string{
+	ScopeSend,
+	ScopeMessagesRead,
+	ScopeLogsRead,
+	ScopeUsageRead,
+	ScopeIdentitiesRead,
+	ScopeTemplatesRead,
+	ScopeTemplatesWrite,
+	ScopeKeyRotate,
+	ScopeWebhooksRead,
+	ScopeWebhooksWrite,
+	ScopeSuppressionsRead,
+	ScopeSuppressionsWrite,
+}
+
+func NormalizeScopes(value string) (string, error) {
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(Scopes))
+	for _, scope := range splitList(value) {
+		scope = strings.ToLower(scope)
+		if !validScope(scope) {
+			return "", fmt.Errorf("无效的权限范围: %s", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *CachedAPIKey) HasScope(scope string) bool {
+	if k.Scopes == "" {
+		return true
+	}
+	for _, s := range strings.Split(k.Scopes, ",") {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("api_key")
+		key, ok := value.(*CachedAPIKey)
+		if !ok || !key.HasScope(scope) {
+			c.JSON(403, gin.H{"error": fmt.Sprintf("API Key缺少权限: %s", scope)})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
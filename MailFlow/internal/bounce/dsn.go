@@ -0,0 +1,221 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+const (
+	KindBounce    = "bounce"
+	KindComplaint = "complaint"
+
+	maxPartSize = 1 << 20
+	maxDepth    = 5
+)
+
+var ErrNotReport = errors.New("不是投递状态通知")
+
+type Report struct {
+	Kind       string
+	Addresses  []string
+	MessageID  string
+	Recipients []RecipientStatus
+	Feedback   string
+}
+
+type RecipientStatus struct {
+	Recipient  string
+	Action     string
+	Status     string
+	Diagnostic string
+}
+
+func (s RecipientStatus) Failed() bool {
+	return s.Action == "failed" || (s.Action == "" && strings.HasPrefix(s.Status, "5"))
+}
+
+func Parse(raw []byte) (*Report, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	for _, name := range []string{"X-Original-To", "Delivered-To", "Envelope-To", "To"} {
+		for _, value := range msg.Header[name] {
+			if list, err := mail.ParseAddressList(value); err == nil {
+				for _, addr := range list {
+					report.Addresses = append(report.Addresses, addr.Address)
+				}
+			} else {
+				report.Addresses = append(report.Addresses, strings.TrimSpace(value))
+			}
+		}
+	}
+
+	if err := report.walk(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return nil, err
+	}
+	if report.Kind == "" {
+		return nil, ErrNotReport
+	}
+	return report, nil
+}
+
+func (r *Report) statusFor(email string) (RecipientStatus, bool) {
+	var first *RecipientStatus
+	for i := range r.Recipients {
+		status := &r.Recipients[i]
+		if !status.Failed() {
+			continue
+		}
+		if strings.EqualFold(status.Recipient, email) {
+			return *status, true
+		}
+		if first == nil {
+			first = status
+		}
+	}
+	if first == nil {
+		return RecipientStatus{}, false
+	}
+	return *first, true
+}
+
+func (r *Report) walk(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	if depth > maxDepth {
+		return nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+
+	body = decodeBody(header, io.LimitReader(body, maxPartSize))
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := r.walk(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	case mediaType == "message/delivery-status", mediaType == "message/global-delivery-status":
+		r.Kind = KindBounce
+		return r.parseDeliveryStatus(body)
+	case mediaType == "message/feedback-report":
+		r.Kind = KindComplaint
+		return r.parseFeedbackReport(body)
+	case mediaType == "text/rfc822-headers", mediaType == "message/rfc822",
+		mediaType == "message/global", mediaType == "message/global-headers":
+		r.parseOriginal(body)
+	}
+	return nil
+}
+
+func (r *Report) parseDeliveryStatus(body io.Reader) error {
+	reader := textproto.NewReader(bufio.NewReader(body))
+
+	if _, err := readFields(reader); err != nil {
+		return nil
+	}
+	for {
+		fields, err := readFields(reader)
+		if len(fields) > 0 {
+			r.Recipients = append(r.Recipients, RecipientStatus{
+				Recipient:  typedValue(fields.Get("Final-Recipient")),
+				Action:     strings.ToLower(strings.TrimSpace(fields.Get("Action"))),
+				Status:     strings.TrimSpace(fields.Get("Status")),
+				Diagnostic: typedValue(fields.Get("Diagnostic-Code")),
+			})
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+func (r *Report) parseFeedbackReport(body io.Reader) error {
+	fields, _ := readFields(textproto.NewReader(bufio.NewReader(body)))
+	r.Feedback = strings.ToLower(strings.TrimSpace(fields.Get("Feedback-Type")))
+	r.Recipients = append(r.Recipients, RecipientStatus{
+		Recipient: typedValue(fields.Get("Original-Rcpt-To")),
+		Action:    "failed",
+	})
+	return nil
+}
+
+func (r *Report) parseOriginal(body io.Reader) {
+	if r.MessageID != "" {
+		return
+	}
+	fields, _ := readFields(textproto.NewReader(bufio.NewReader(body)))
+	r.MessageID = strings.TrimSpace(fields.Get("Message-Id"))
+}
+
+func readFields(reader *textproto.Reader) (textproto.MIMEHeader, error) {
+	for {
+		peek, err := reader.R.Peek(1)
+		if err != nil {
+			return textproto.MIMEHeader{}, err
+		}
+		if peek[0] != '\r' && peek[0] != '\n' {
+			break
+		}
+		if _, err := reader.ReadLine(); err != nil {
+			return textproto.MIMEHeader{}, err
+		}
+	}
+	return reader.ReadMIMEHeader()
+}
+
+func typedValue(value string) string {
+	value = strings.TrimSpace(value)
+	if i := strings.Index(value, ";"); i >= 0 {
+		value = strings.TrimSpace(value[i+1:])
+	}
+	return value
+}
+
+func decodeBody(header textproto.MIMEHeader, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: body})
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (s *newlineStripper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	out := p[:0]
+	for _, b := range p[:n] {
+		if b != '\r' && b != '\n' && b != ' ' && b != '\t' {
+			out = append(out, b)
+		}
+	}
+	return len(out), err
+}
@@ -0,0 +1,195 @@
+package bounce
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const recipientID = "3f2b8c1e-9d4a-4f6b-8e2c-1a7d5b9c0e44"
+
+func message(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\r\n"))
+}
+
+func TestParseDeliveryStatus(t *testing.T) {
+	raw := message(
+		"From: MAILER-DAEMON@mx.example.net",
+		"To: bounces+"+recipientID+"@mail.example.com",
+		"Subject: Undelivered Mail Returned to Sender",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/report; report-type=delivery-status; boundary="b1"`,
+		"",
+		"--b1",
+		"Content-Type: text/plain",
+		"",
+		"Your message could not be delivered.",
+		"--b1",
+		"Content-Type: message/delivery-status",
+		"",
+		"Reporting-MTA: dns; mx.example.net",
+		"",
+		"Final-Recipient: rfc822; delivered@example.org",
+		"Action: delivered",
+		"Status: 2.0.0",
+		"",
+		"Final-Recipient: rfc822; User@Example.org",
+		"Action: failed",
+		"Status: 5.1.1",
+		"Diagnostic-Code: smtp; 550 5.1.1 <user@example.org>: Recipient address rejected",
+		"",
+		"--b1",
+		"Content-Type: text/rfc822-headers",
+		"",
+		"From: sender@example.com",
+		"Message-ID: <"+recipientID+"@example.com>",
+		"Subject: Hello",
+		"",
+		"--b1--",
+		"",
+	)
+
+	report, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Kind != KindBounce {
+		t.Fatalf("Kind = %q", report.Kind)
+	}
+	if len(report.Addresses) != 1 || report.Addresses[0] != "bounces+"+recipientID+"@mail.example.com" {
+		t.Fatalf("Addresses = %v", report.Addresses)
+	}
+	if report.MessageID != "<"+recipientID+"@example.com>" {
+		t.Fatalf("MessageID = %q", report.MessageID)
+	}
+	if len(report.Recipients) != 2 {
+		t.Fatalf("Recipients = %+v", report.Recipients)
+	}
+
+	status, ok := report.statusFor("user@example.org")
+	if !ok {
+		t.Fatal("failed recipient not found")
+	}
+	if status.Status != "5.1.1" || status.Action != "failed" {
+		t.Fatalf("status = %+v", status)
+	}
+	if status.Diagnostic != "550 5.1.1 <user@example.org>: Recipient address rejected" {
+		t.Fatalf("Diagnostic = %q", status.Diagnostic)
+	}
+
+	if status, ok := report.statusFor("other@example.org"); !ok || status.Recipient != "User@Example.org" {
+		t.Fatalf("unknown recipient should fall back to the first failure: %+v", status)
+	}
+}
+
+func TestParseDeliveryStatusBase64(t *testing.T) {
+	raw := message(
+		"From: MAILER-DAEMON@mx.example.net",
+		"To: bounces@mail.example.com",
+		`Content-Type: multipart/report; report-type=delivery-status; boundary="b1"`,
+		"",
+		"--b1",
+		"Content-Type: message/delivery-status",
+		"Content-Transfer-Encoding: base64",
+		"",
+		"UmVwb3J0aW5nLU1UQTogZG5zOyBteC5leGFtcGxlLm5ldA0KDQpGaW5hbC1SZWNpcGllbnQ6IHJm",
+		"YzgyMjsgdXNlckBleGFtcGxlLm9yZw0KQWN0aW9uOiBmYWlsZWQNClN0YXR1czogNS4yLjENCg==",
+		"--b1--",
+		"",
+	)
+
+	report, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Recipients) != 1 || report.Recipients[0].Status != "5.2.1" || report.Recipients[0].Recipient != "user@example.org" {
+		t.Fatalf("Recipients = %+v", report.Recipients)
+	}
+}
+
+func TestParseDelayedOnly(t *testing.T) {
+	raw := message(
+		"From: MAILER-DAEMON@mx.example.net",
+		"To: bounces@mail.example.com",
+		`Content-Type: multipart/report; report-type=delivery-status; boundary="b1"`,
+		"",
+		"--b1",
+		"Content-Type: message/delivery-status",
+		"",
+		"Reporting-MTA: dns; mx.example.net",
+		"",
+		"Final-Recipient: rfc822; user@example.org",
+		"Action: delayed",
+		"Status: 4.4.1",
+		"",
+		"--b1--",
+		"",
+	)
+
+	report, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := report.statusFor("user@example.org"); ok {
+		t.Fatal("delayed delivery must not be treated as a bounce")
+	}
+}
+
+func TestParseFeedbackReport(t *testing.T) {
+	raw := message(
+		"From: fbl@isp.example.net",
+		"To: fbl@mail.example.com",
+		`Content-Type: multipart/report; report-type=feedback-report; boundary="b2"`,
+		"",
+		"--b2",
+		"Content-Type: text/plain",
+		"",
+		"This is an abuse report.",
+		"--b2",
+		"Content-Type: message/feedback-report",
+		"",
+		"Feedback-Type: Abuse",
+		"User-Agent: ExampleFBL/1.0",
+		"Version: 1",
+		"Original-Rcpt-To: rfc822; user@example.org",
+		"",
+		"--b2",
+		"Content-Type: message/rfc822",
+		"",
+		"From: sender@example.com",
+		"To: user@example.org",
+		"Message-ID: <"+recipientID+"@example.com>",
+		"",
+		"Hello",
+		"--b2--",
+		"",
+	)
+
+	report, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Kind != KindComplaint || report.Feedback != "abuse" {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.MessageID != "<"+recipientID+"@example.com>" {
+		t.Fatalf("MessageID = %q", report.MessageID)
+	}
+	if status, ok := report.statusFor("user@example.org"); !ok || status.Recipient != "user@example.org" {
+		t.Fatalf("status = %+v, %v", status, ok)
+	}
+}
+
+func TestParseNotReport(t *testing.T) {
+	raw := message(
+		"From: someone@example.org",
+		"To: bounces@mail.example.com",
+		"Content-Type: text/plain",
+		"",
+		"Out of office until Monday.",
+	)
+
+	if _, err := Parse(raw); !errors.Is(err, ErrNotReport) {
+		t.Fatalf("Parse = %v, want ErrNotReport", err)
+	}
+}
@@ -0,0 +1,145 @@
+package bounce
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+func pollIMAP(config *models.SMTPConfig, handle func(raw []byte) bool) (int, error) {
+	c, err := openIMAP(config, false)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Logout()
+
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag, imap.DeletedFlag}
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return 0, fmt.Errorf("搜索邮件失败: %w", err)
+	}
+	if len(uids) == 0 {
+		return 0, nil
+	}
+	if len(uids) > FetchLimit {
+		uids = uids[:FetchLimit]
+	}
+
+	fetchSet := new(imap.SeqSet)
+	fetchSet.AddNum(uids...)
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(fetchSet, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, messages)
+	}()
+
+	seen := new(imap.SeqSet)
+	handled := new(imap.SeqSet)
+	processed := 0
+	for msg := range messages {
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+		raw, err := io.ReadAll(io.LimitReader(body, MaxMessageSize))
+		if err != nil {
+			continue
+		}
+		seen.AddNum(msg.Uid)
+		if handle(raw) {
+			handled.AddNum(msg.Uid)
+			processed++
+		}
+	}
+	if err := <-done; err != nil {
+		return processed, fmt.Errorf("读取邮件失败: %w", err)
+	}
+
+	if !seen.Empty() {
+		flags := []interface{}{imap.SeenFlag}
+		if err := c.UidStore(seen, imap.FormatFlagsOp(imap.AddFlags, true), flags, nil); err != nil {
+			return processed, fmt.Errorf("标记已读失败: %w", err)
+		}
+	}
+	if !handled.Empty() {
+		flags := []interface{}{imap.DeletedFlag}
+		if err := c.UidStore(handled, imap.FormatFlagsOp(imap.AddFlags, true), flags, nil); err != nil {
+			return processed, fmt.Errorf("删除邮件失败: %w", err)
+		}
+		if err := c.Expunge(nil); err != nil {
+			return processed, fmt.Errorf("删除邮件失败: %w", err)
+		}
+	}
+	return processed, nil
+}
+
+func testIMAP(config *models.SMTPConfig) error {
+	c, err := openIMAP(config, true)
+	if err != nil {
+		return err
+	}
+	return c.Logout()
+}
+
+func openIMAP(config *models.SMTPConfig, readOnly bool) (*client.Client, error) {
+	c, err := dialIMAP(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Login(config.BounceUsername, config.BouncePassword); err != nil {
+		c.Logout()
+		return nil, fmt.Errorf("IMAP登录失败: %w", err)
+	}
+
+	mailbox := config.BounceMailbox
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+	if _, err := c.Select(mailbox, readOnly); err != nil {
+		c.Logout()
+		return nil, fmt.Errorf("打开邮箱目录失败: %w", err)
+	}
+	return c, nil
+}
+
+func dialIMAP(config *models.SMTPConfig) (*client.Client, error) {
+	port := config.BouncePort
+	if port == 0 {
+		port = 993
+		if config.BounceSecurity != "ssl" {
+			port = 143
+		}
+	}
+	addr := net.JoinHostPort(config.BounceHost, strconv.Itoa(port))
+	dialer := &net.Dialer{Timeout: DialTimeout}
+	tlsConfig := &tls.Config{ServerName: config.BounceHost}
+
+	var c *client.Client
+	var err error
+	if config.BounceSecurity == "ssl" {
+		c, err = client.DialWithDialerTLS(dialer, addr, tlsConfig)
+	} else {
+		c, err = client.DialWithDialer(dialer, addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接IMAP服务器失败: %w", err)
+	}
+	c.Timeout = CommandTimeout
+
+	if config.BounceSecurity == "starttls" {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Logout()
+			return nil, fmt.Errorf("STARTTLS失败: %w", err)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,169 @@
+package bounce
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+)
+
+type pop3Conn struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+func pollPOP3(ctx context.Context, config *models.SMTPConfig, handle func(raw []byte) bool) (int, error) {
+	c, err := openPOP3(config)
+	if err != nil {
+		return 0, err
+	}
+	defer c.close()
+
+	lines, err := c.multiline("UIDL")
+	if err != nil {
+		return 0, fmt.Errorf("获取邮件列表失败: %w", err)
+	}
+
+	seenKey := fmt.Sprintf("mailflow:bounce:seen:%d", config.ID)
+	processed, fetched := 0, 0
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		number, uid := fields[0], fields[1]
+
+		if seen, _ := queue.Client.SIsMember(ctx, seenKey, uid).Result(); seen {
+			continue
+		}
+		if fetched >= FetchLimit {
+			break
+		}
+		fetched++
+
+		body, err := c.multiline("RETR " + number)
+		if err != nil {
+			return processed, fmt.Errorf("读取邮件失败: %w", err)
+		}
+		raw := []byte(strings.Join(body, "\r\n") + "\r\n")
+		if len(raw) > MaxMessageSize {
+			raw = raw[:MaxMessageSize]
+		}
+
+		if !handle(raw) {
+			queue.Client.SAdd(ctx, seenKey, uid)
+			queue.Client.Expire(ctx, seenKey, SeenTTL)
+			continue
+		}
+		if _, err := c.command("DELE " + number); err != nil {
+			return processed, fmt.Errorf("删除邮件失败: %w", err)
+		}
+		processed++
+	}
+
+	if _, err := c.command("QUIT"); err != nil {
+		return processed, fmt.Errorf("提交删除失败: %w", err)
+	}
+	return processed, nil
+}
+
+func testPOP3(config *models.SMTPConfig) error {
+	c, err := openPOP3(config)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	_, err = c.command("QUIT")
+	return err
+}
+
+func openPOP3(config *models.SMTPConfig) (*pop3Conn, error) {
+	port := config.BouncePort
+	if port == 0 {
+		port = 995
+		if config.BounceSecurity != "ssl" {
+			port = 110
+		}
+	}
+	addr := net.JoinHostPort(config.BounceHost, strconv.Itoa(port))
+	dialer := &net.Dialer{Timeout: DialTimeout}
+	tlsConfig := &tls.Config{ServerName: config.BounceHost}
+
+	var conn net.Conn
+	var err error
+	if config.BounceSecurity == "ssl" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接POP3服务器失败: %w", err)
+	}
+
+	c := &pop3Conn{conn: conn, text: textproto.NewConn(conn)}
+	if _, err := c.response(); err != nil {
+		c.close()
+		return nil, fmt.Errorf("POP3服务器响应异常: %w", err)
+	}
+
+	if config.BounceSecurity == "starttls" {
+		if _, err := c.command("STLS"); err != nil {
+			c.close()
+			return nil, fmt.Errorf("STLS失败: %w", err)
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			c.close()
+			return nil, fmt.Errorf("STLS失败: %w", err)
+		}
+		c.conn = tlsConn
+		c.text = textproto.NewConn(tlsConn)
+	}
+
+	if _, err := c.command("USER " + config.BounceUsername); err != nil {
+		c.close()
+		return nil, fmt.Errorf("POP3登录失败: %w", err)
+	}
+	if _, err := c.command("PASS " + config.BouncePassword); err != nil {
+		c.close()
+		return nil, fmt.Errorf("POP3登录失败: %w", err)
+	}
+	return c, nil
+}
+
+func (c *pop3Conn) command(cmd string) (string, error) {
+	c.conn.SetDeadline(time.Now().Add(CommandTimeout))
+	if err := c.text.PrintfLine("%s", cmd); err != nil {
+		return "", err
+	}
+	return c.response()
+}
+
+func (c *pop3Conn) multiline(cmd string) ([]string, error) {
+	if _, err := c.command(cmd); err != nil {
+		return nil, err
+	}
+	return c.text.ReadDotLines()
+}
+
+func (c *pop3Conn) response() (string, error) {
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(line, "+OK") {
+		return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+	}
+	return "", fmt.Errorf("%s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+}
+
+func (c *pop3Conn) close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,194 @@
+package bounce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/events"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/mailflow/smtp-loadbalancer/internal/suppression"
+)
+
+const (
+	ProtocolIMAP = "imap"
+	ProtocolPOP3 = "pop3"
+
+	PollInterval   = 2 * time.Minute
+	FetchLimit     = 100
+	MaxMessageSize = 10 << 20
+	DialTimeout    = 15 * time.Second
+	CommandTimeout = time.Minute
+	SeenTTL        = 30 * 24 * time.Hour
+
+	lockTTL = 10 * time.Minute
+)
+
+var errUnsupportedProtocol = errors.New("不支持的退信邮箱协议")
+
+func Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pollAll(ctx)
+			}
+		}
+	}()
+}
+
+func Test(config *models.SMTPConfig) error {
+	switch config.BounceProtocol {
+	case ProtocolIMAP:
+		return testIMAP(config)
+	case ProtocolPOP3:
+		return testPOP3(config)
+	}
+	return errUnsupportedProtocol
+}
+
+func pollAll(ctx context.Context) {
+	var configs []models.SMTPConfig
+	if err := database.DB.Where("bounce_protocol IN ? AND bounce_host <> ?", []string{ProtocolIMAP, ProtocolPOP3}, "").
+		Find(&configs).Error; err != nil {
+		log.Printf("查询退信邮箱配置失败: %v", err)
+		return
+	}
+
+	for i := range configs {
+		if ctx.Err() != nil {
+			return
+		}
+		poll(ctx, &configs[i])
+	}
+}
+
+func poll(ctx context.Context, config *models.SMTPConfig) {
+	lockKey := fmt.Sprintf("mailflow:bounce:lock:%d", config.ID)
+	locked, err := queue.Client.SetNX(ctx, lockKey, 1, lockTTL).Result()
+	if err != nil || !locked {
+		return
+	}
+	defer queue.Client.Del(ctx, lockKey)
+
+	handle := func(raw []byte) bool {
+		return process(ctx, config, raw)
+	}
+
+	var processed int
+	switch config.BounceProtocol {
+	case ProtocolIMAP:
+		processed, err = pollIMAP(config, handle)
+	case ProtocolPOP3:
+		processed, err = pollPOP3(ctx, config, handle)
+	}
+
+	errorMsg := ""
+	if err != nil {
+		errorMsg = err.Error()
+		log.Printf("SMTP[%s] 退信邮箱处理失败: %v", config.Name, err)
+	} else if processed > 0 {
+		log.Printf("SMTP[%s] 处理了 %d 封退信", config.Name, processed)
+	}
+
+	now := time.Now()
+	database.DB.Model(&models.SMTPConfig{}).Where("id = ?", config.ID).Updates(map[string]interface{}{
+		"bounce_polled_at": &now,
+		"bounce_error":     errorMsg,
+	})
+}
+
+func process(ctx context.Context, config *models.SMTPConfig, raw []byte) bool {
+	report, err := Parse(raw)
+	if err != nil {
+		return false
+	}
+
+	recipientID := matchRecipient(config, report)
+	if recipientID == "" {
+		log.Printf("SMTP[%s] 收到无法匹配的退信 [Message-ID: %s]", config.Name, report.MessageID)
+		return false
+	}
+
+	var sendLog models.SendLog
+	if err := database.DB.Where("recipient_id = ?", recipientID).First(&sendLog).Error; err != nil {
+		log.Printf("SMTP[%s] 退信对应的发送记录不存在 [%s]", config.Name, recipientID)
+		return false
+	}
+
+	status, ok := report.statusFor(sendLog.To)
+	if !ok {
+		return true
+	}
+
+	data := map[string]interface{}{
+		"message_id":   sendLog.MessageID,
+		"recipient_id": sendLog.RecipientID,
+		"to":           sendLog.To,
+		"subject":      sendLog.Subject,
+	}
+
+	if report.Kind == KindComplaint {
+		data["feedback_type"] = report.Feedback
+		suppression.RecordComplaint(sendLog.APIKeyID, sendLog.To, suppression.SourceFBL, report.Feedback)
+		if err := events.Publish(ctx, events.EmailComplained, sendLog.APIKeyID, data); err != nil {
+			log.Printf("发布事件失败 [%s] [%s]: %v", events.EmailComplained, sendLog.To, err)
+		}
+		return true
+	}
+
+	errorMsg := "退信"
+	if status.Status != "" {
+		errorMsg += " " + status.Status
+	}
+	if status.Diagnostic != "" {
+		errorMsg += ": " + status.Diagnostic
+	}
+
+	result := database.DB.Model(&models.SendLog{}).
+		Where("id = ? AND status = ?", sendLog.ID, "success").
+		Updates(map[string]interface{}{
+			"status":     "bounced",
+			"error_msg":  errorMsg,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return true
+	}
+	suppression.RecordBounce(sendLog.To, suppression.SourceDSN, errorMsg)
+
+	data["status"] = status.Status
+	data["diagnostic_code"] = status.Diagnostic
+	data["error"] = errorMsg
+	if err := events.Publish(ctx, events.EmailBounced, sendLog.APIKeyID, data); err != nil {
+		log.Printf("发布事件失败 [%s] [%s]: %v", events.EmailBounced, sendLog.To, err)
+	}
+	log.Printf("邮件退信 [收件人: %s]: %s", sendLog.To, errorMsg)
+	return true
+}
+
+func matchRecipient(config *models.SMTPConfig, report *Report) string {
+	if config.ReturnPath != "" {
+		for _, addr := range report.Addresses {
+			if id, ok := recipientFromAddress(config.ReturnPath, addr); ok {
+				return id
+			}
+		}
+	}
+	if id, ok := recipientFromMessageID(report.MessageID); ok {
+		return id
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package bounce
+
+import (
+	"net/mail"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+func ReturnPath(base, recipientID string) string {
+	local, domain, ok := splitAddress(base)
+	if !ok || recipientID == "" {
+		return base
+	}
+	return local + "+" + recipientID + "@" + domain
+}
+
+func MessageID(recipientID, fromEmail string) string {
+	_, domain, ok := splitAddress(fromEmail)
+	if !ok {
+		domain = "mailflow.local"
+	}
+	return "<" + recipientID + "@" + domain + ">"
+}
+
+func recipientFromAddress(base, address string) (string, bool) {
+	local, domain, ok := splitAddress(base)
+	if !ok {
+		return "", false
+	}
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		address = parsed.Address
+	}
+	addrLocal, addrDomain, ok := splitAddress(address)
+	if !ok || !strings.EqualFold(addrDomain, domain) {
+		return "", false
+	}
+
+	prefix := strings.ToLower(local) + "+"
+	if !strings.HasPrefix(strings.ToLower(addrLocal), prefix) {
+		return "", false
+	}
+	return parseRecipientID(addrLocal[len(prefix):])
+}
+
+func recipientFromMessageID(messageID string) (string, bool) {
+	messageID = strings.Trim(strings.TrimSpace(messageID), "<>")
+	at := strings.LastIndex(messageID, "@")
+	if at < 0 {
+		return "", false
+	}
+	return parseRecipientID(messageID[:at])
+}
+
+func parseRecipientID(token string) (string, bool) {
+	id, err := uuid.Parse(token)
+	if err != nil {
+		return "", false
+	}
+	return id.String(), true
+}
+
+func splitAddress(address string) (string, string, bool) {
+	address = strings.TrimSpace(address)
+	at := strings.LastIndex(address, "@")
+	if at <= 0 || at == len(address)-1 {
+		return "", "", false
+	}
+	return address[:at], address[at+1:], true
+}
@@ -0,0 +1,89 @@
+package bounce
+
+import (
+	"testing"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+func TestReturnPath(t *testing.T) {
+	if got := ReturnPath("bounces@mail.example.com", recipientID); got != "bounces+"+recipientID+"@mail.example.com" {
+		t.Fatalf("ReturnPath = %q", got)
+	}
+	if got := ReturnPath("bounces@mail.example.com", ""); got != "bounces@mail.example.com" {
+		t.Fatalf("ReturnPath without id = %q", got)
+	}
+	if got := ReturnPath("invalid", recipientID); got != "invalid" {
+		t.Fatalf("ReturnPath with invalid base = %q", got)
+	}
+}
+
+func TestRecipientFromAddress(t *testing.T) {
+	base := "bounces@mail.example.com"
+	tests := []struct {
+		address string
+		want    string
+		ok      bool
+	}{
+		{ReturnPath(base, recipientID), recipientID, true},
+		{"Mail Delivery <Bounces+" + recipientID + "@MAIL.example.com>", recipientID, true},
+		{"bounces+" + recipientID + "@other.example.com", "", false},
+		{"other+" + recipientID + "@mail.example.com", "", false},
+		{"bounces+not-a-uuid@mail.example.com", "", false},
+		{"bounces@mail.example.com", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := recipientFromAddress(base, tt.address)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("recipientFromAddress(%q) = (%q, %v), want (%q, %v)", tt.address, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMessageID(t *testing.T) {
+	if got := MessageID(recipientID, "Sender@example.com"); got != "<"+recipientID+"@example.com>" {
+		t.Fatalf("MessageID = %q", got)
+	}
+	if got := MessageID(recipientID, ""); got != "<"+recipientID+"@mailflow.local>" {
+		t.Fatalf("MessageID without sender = %q", got)
+	}
+
+	tests := []struct {
+		messageID string
+		want      string
+		ok        bool
+	}{
+		{MessageID(recipientID, "sender@example.com"), recipientID, true},
+		{" " + recipientID + "@example.com ", recipientID, true},
+		{"<20030712040037.46341.5F8J@example.com>", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := recipientFromMessageID(tt.messageID)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("recipientFromMessageID(%q) = (%q, %v), want (%q, %v)", tt.messageID, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMatchRecipient(t *testing.T) {
+	report := &Report{
+		Addresses: []string{"postmaster@example.org", "bounces+" + recipientID + "@mail.example.com"},
+		MessageID: "<unrelated@example.com>",
+	}
+	config := &models.SMTPConfig{ReturnPath: "bounces@mail.example.com"}
+	if got := matchRecipient(config, report); got != recipientID {
+		t.Fatalf("matchRecipient by VERP = %q", got)
+	}
+
+	report = &Report{MessageID: MessageID(recipientID, "sender@example.com")}
+	if got := matchRecipient(&models.SMTPConfig{}, report); got != recipientID {
+		t.Fatalf("matchRecipient by Message-ID = %q", got)
+	}
+
+	if got := matchRecipient(config, &Report{}); got != "" {
+		t.Fatalf("matchRecipient without match = %q", got)
+	}
+}
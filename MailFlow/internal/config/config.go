@@ -2,21 +2,31 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	Redis    RedisConfig    `yaml:"redis"`
-	Worker   WorkerConfig   `yaml:"worker"`
-	Admin    AdminConfig    `yaml:"admin"`
+	Server      ServerConfig      `yaml:"server"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Redis       RedisConfig       `yaml:"redis"`
+	Worker      WorkerConfig      `yaml:"worker"`
+	Admin       AdminConfig       `yaml:"admin"`
+	Session     SessionConfig     `yaml:"session"`
+	Unsubscribe UnsubscribeConfig `yaml:"unsubscribe"`
 }
 
 type ServerConfig struct {
-	Port int `yaml:"port"`
+	Port                int           `yaml:"port"`
+	MaxAttachmentSize   int           `yaml:"max_attachment_size"`
+	MaxBatchSize        int           `yaml:"max_batch_size"`
+	KeyRotationGrace    time.Duration `yaml:"key_rotation_grace"`
+	TrustedProxies      []string      `yaml:"trusted_proxies"`
+	WebhookAllowPrivate bool          `yaml:"webhook_allow_private"`
 }
 
 type DatabaseConfig struct {
@@ -35,7 +45,11 @@ type RedisConfig struct {
 }
 
 type WorkerConfig struct {
-	Count int `yaml:"count"`
+	Count          int           `yaml:"count"`
+	MaxAttempts    int           `yaml:"max_attempts"`
+	MaxAge         time.Duration `yaml:"max_age"`
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay"`
 }
 
 type AdminConfig struct {
@@ -43,6 +57,19 @@ type AdminConfig struct {
 	Password string `yaml:"password"`
 }
 
+type SessionConfig struct {
+	Secrets  []string      `yaml:"secrets"`
+	Store    string        `yaml:"store"`
+	Secure   bool          `yaml:"secure"`
+	SameSite string        `yaml:"same_site"`
+	MaxAge   time.Duration `yaml:"max_age"`
+}
+
+type UnsubscribeConfig struct {
+	BaseURL string `yaml:"base_url"`
+	Secret  string `yaml:"secret"`
+}
+
 func Load(filename string) (*Config, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -67,6 +94,9 @@ func overrideFromEnv(cfg *Config) {
 	if port := os.Getenv("SERVER_PORT"); port != "" {
 		fmt.Sscanf(port, "%d", &cfg.Server.Port)
 	}
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		cfg.Server.TrustedProxies = strings.Split(proxies, ",")
+	}
 	if host := os.Getenv("DB_HOST"); host != "" {
 		cfg.Database.Host = host
 	}
@@ -94,18 +124,50 @@ func overrideFromEnv(cfg *Config) {
 	if count := os.Getenv("WORKER_COUNT"); count != "" {
 		fmt.Sscanf(count, "%d", &cfg.Worker.Count)
 	}
+	if attempts := os.Getenv("WORKER_MAX_ATTEMPTS"); attempts != "" {
+		fmt.Sscanf(attempts, "%d", &cfg.Worker.MaxAttempts)
+	}
+	if maxAge := os.Getenv("WORKER_MAX_AGE"); maxAge != "" {
+		if d, err := time.ParseDuration(maxAge); err == nil {
+			cfg.Worker.MaxAge = d
+		}
+	}
 	if username := os.Getenv("ADMIN_USERNAME"); username != "" {
 		cfg.Admin.Username = username
 	}
 	if password := os.Getenv("ADMIN_PASSWORD"); password != "" {
 		cfg.Admin.Password = password
 	}
+	if secrets := os.Getenv("SESSION_SECRETS"); secrets != "" {
+		cfg.Session.Secrets = strings.Split(secrets, ",")
+	}
+	if store := os.Getenv("SESSION_STORE"); store != "" {
+		cfg.Session.Store = store
+	}
+	if secure := os.Getenv("SESSION_SECURE"); secure != "" {
+		cfg.Session.Secure = secure == "true" || secure == "1"
+	}
+	if baseURL := os.Getenv("UNSUBSCRIBE_BASE_URL"); baseURL != "" {
+		cfg.Unsubscribe.BaseURL = baseURL
+	}
+	if secret := os.Getenv("UNSUBSCRIBE_SECRET"); secret != "" {
+		cfg.Unsubscribe.Secret = secret
+	}
 }
 
 func validate(cfg *Config) error {
 	if cfg.Server.Port == 0 {
 		cfg.Server.Port = 8080
 	}
+	if cfg.Server.MaxAttachmentSize == 0 {
+		cfg.Server.MaxAttachmentSize = 10
+	}
+	if cfg.Server.MaxBatchSize == 0 {
+		cfg.Server.MaxBatchSize = 500
+	}
+	if cfg.Server.KeyRotationGrace == 0 {
+		cfg.Server.KeyRotationGrace = 24 * time.Hour
+	}
 	if cfg.Database.Host == "" {
 		return fmt.Errorf("数据库host不能为空")
 	}
@@ -121,9 +183,57 @@ func validate(cfg *Config) error {
 	if cfg.Worker.Count == 0 {
 		cfg.Worker.Count = 5
 	}
+	if cfg.Worker.MaxAttempts == 0 {
+		cfg.Worker.MaxAttempts = 5
+	}
+	if cfg.Worker.MaxAge == 0 {
+		cfg.Worker.MaxAge = 24 * time.Hour
+	}
+	if cfg.Worker.RetryBaseDelay == 0 {
+		cfg.Worker.RetryBaseDelay = 30 * time.Second
+	}
+	if cfg.Worker.RetryMaxDelay == 0 {
+		cfg.Worker.RetryMaxDelay = time.Hour
+	}
 	if cfg.Admin.Username == "" || cfg.Admin.Password == "" {
 		return fmt.Errorf("管理员用户名和密码不能为空")
 	}
+	for _, secret := range cfg.Session.Secrets {
+		if len(secret) < 32 {
+			return fmt.Errorf("会话密钥长度不能少于32个字符")
+		}
+	}
+	if cfg.Session.Store == "" {
+		cfg.Session.Store = "cookie"
+	}
+	if cfg.Session.Store != "cookie" && cfg.Session.Store != "redis" {
+		return fmt.Errorf("无效的会话存储类型: %s", cfg.Session.Store)
+	}
+	if cfg.Session.SameSite == "" {
+		cfg.Session.SameSite = "lax"
+	}
+	switch cfg.Session.SameSite {
+	case "lax", "strict":
+	case "none":
+		if !cfg.Session.Secure {
+			return fmt.Errorf("same_site为none时必须启用secure")
+		}
+	default:
+		return fmt.Errorf("无效的same_site: %s", cfg.Session.SameSite)
+	}
+	if cfg.Session.MaxAge == 0 {
+		cfg.Session.MaxAge = 7 * 24 * time.Hour
+	}
+	cfg.Unsubscribe.BaseURL = strings.TrimRight(strings.TrimSpace(cfg.Unsubscribe.BaseURL), "/")
+	if cfg.Unsubscribe.BaseURL != "" {
+		u, err := url.Parse(cfg.Unsubscribe.BaseURL)
+		if err != nil || u.Host == "" || u.Scheme != "https" {
+			return fmt.Errorf("退订base_url必须是有效的https地址")
+		}
+	}
+	if cfg.Unsubscribe.Secret != "" && len(cfg.Unsubscribe.Secret) < 32 {
+		return fmt.Errorf("退订密钥长度不能少于32个字符")
+	}
 	return nil
 }
 
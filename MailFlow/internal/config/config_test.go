@@ -0,0 +1,40 @@
+package config
+
+import "testing"
+
+func validConfig() *Config {
+	return &Config{
+		Database: DatabaseConfig{Host: "localhost"},
+		Redis:    RedisConfig{Addr: "localhost:6379"},
+		Admin:    AdminConfig{Username: "admin", Password: "secret"},
+	}
+}
+
+func TestValidateUnsubscribeBaseURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{" https://mail.example.com/ ", "https://mail.example.com", false},
+		{"https://mail.example.com:8443/mailflow", "https://mail.example.com:8443/mailflow", false},
+		{"http://mail.example.com", "", true},
+		{"http://203.0.113.7:8080", "", true},
+		{"mail.example.com", "", true},
+		{"https://", "", true},
+	}
+
+	for _, tt := range tests {
+		cfg := validConfig()
+		cfg.Unsubscribe.BaseURL = tt.baseURL
+		err := validate(cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("base_url %q: error = %v, wantErr %v", tt.baseURL, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.Unsubscribe.BaseURL != tt.want {
+			t.Errorf("base_url %q normalized to %q, want %q", tt.baseURL, cfg.Unsubscribe.BaseURL, tt.want)
+		}
+	}
+}
@@ -32,6 +32,14 @@ func Connect(cfg *config.DatabaseConfig) error {
 	sqlDB.SetMaxIdleConns(10)
 	sqlDB.SetMaxOpenConns(100)
 
+	if err := migrateAPIKeyHashes(db); err != nil {
+		return fmt.Errorf("API Key迁移失败: %w", err)
+	}
+
+	if err := migrateAdminTokenRoles(db); err != nil {
+		return fmt.Errorf("管理Token角色迁移失败: %w", err)
+	}
+
 	if err := models.AutoMigrate(db); err != nil {
 		return fmt.Errorf("数据库迁移失败: %w", err)
 	}
@@ -3,9 +3,103 @@ package database
 import (
 	"log"
 
+	"github.com/mailflow/smtp-loadbalancer/internal/keys"
 	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+func migrateAPIKeyHashes(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable("api_keys") || !migrator.HasColumn("api_keys", "key") {
+		return nil
+	}
+
+	if err := db.Exec(`ALTER TABLE api_keys
+		ADD COLUMN IF NOT EXISTS key_hash text,
+		ADD COLUMN IF NOT EXISTS key_prefix text,
+		ADD COLUMN IF NOT EXISTS key_last4 text`).Error; err != nil {
+		return err
+	}
+
+	type legacyKey struct {
+		ID  uint
+		Key string
+	}
+
+	var legacy []legacyKey
+	if err := db.Table("api_keys").Select("id, key").Where("key_hash IS NULL OR key_hash = ''").Find(&legacy).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range legacy {
+			secret := keys.Parse(row.Key)
+			if err := tx.Table("api_keys").Where("id = ?", row.ID).Updates(map[string]interface{}{
+				"key_hash":   secret.Hash,
+				"key_prefix": secret.Prefix,
+				"key_last4":  secret.Last4,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("ALTER TABLE api_keys DROP COLUMN key").Error; err != nil {
+			return err
+		}
+
+		log.Printf("已将%d个API Key迁移为哈希存储", len(legacy))
+		return nil
+	})
+}
+
+func migrateAdminTokenRoles(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable("admin_tokens") || migrator.HasColumn("admin_tokens", "role") {
+		return nil
+	}
+
+	if err := db.Exec("ALTER TABLE admin_tokens ADD COLUMN role text NOT NULL DEFAULT 'readonly'").Error; err != nil {
+		return err
+	}
+
+	var count int64
+	db.Table("admin_tokens").Count(&count)
+	if count > 0 {
+		log.Printf("警告: 已将%d个历史管理Token设为readonly只读角色，如需写权限请在管理后台重新创建Token", count)
+	}
+	return nil
+}
+
+func InitDefaultAdmin(username, password string) error {
+	var count int64
+	if err := DB.Model(&models.AdminUser{}).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	owner := models.AdminUser{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         "owner",
+		IsActive:     true,
+	}
+	if err := DB.Create(&owner).Error; err != nil {
+		return err
+	}
+
+	log.Printf("已根据配置文件创建所有者账号: %s", username)
+	return nil
+}
+
 func InitDefaultPlans() error {
 	var count int64
 	if err := DB.Model(&models.Plan{}).Count(&count).Error; err != nil {
@@ -0,0 +1,85 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+)
+
+func Add(task *queue.EmailTask, recipient queue.Recipient, attempts []queue.AttemptError) error {
+	single := *task
+	single.Recipients = []queue.Recipient{recipient}
+
+	payload, err := json.Marshal(&single)
+	if err != nil {
+		return fmt.Errorf("序列化邮件任务失败: %w", err)
+	}
+
+	history, err := json.Marshal(attempts)
+	if err != nil {
+		return fmt.Errorf("序列化错误记录失败: %w", err)
+	}
+
+	var lastError string
+	if len(attempts) > 0 {
+		lastError = attempts[len(attempts)-1].Error
+	}
+
+	entry := models.DeadLetter{
+		MessageID:   task.MessageID,
+		RecipientID: recipient.ID,
+		APIKeyID:    task.APIKeyID,
+		To:          recipient.Email,
+		Subject:     task.Subject,
+		Attempts:    len(attempts),
+		LastError:   lastError,
+		Errors:      string(history),
+		Payload:     string(payload),
+		CreatedAt:   time.Now(),
+	}
+	return database.DB.Create(&entry).Error
+}
+
+func Replay(ctx context.Context, id uint) error {
+	var entry models.DeadLetter
+	if err := database.DB.First(&entry, id).Error; err != nil {
+		return fmt.Errorf("死信不存在")
+	}
+
+	var task queue.EmailTask
+	if err := json.Unmarshal([]byte(entry.Payload), &task); err != nil {
+		return fmt.Errorf("反序列化邮件任务失败: %w", err)
+	}
+
+	task.Attempt = 0
+	task.Errors = nil
+	task.CreatedAt = time.Now()
+	task.QuotaReservedAt = nil
+
+	setLogStatus(entry.RecipientID, "queued", "")
+
+	if err := queue.PushEmail(ctx, &task); err != nil {
+		setLogStatus(entry.RecipientID, "failed", entry.LastError)
+		return fmt.Errorf("邮件入队失败: %w", err)
+	}
+
+	return database.DB.Delete(&models.DeadLetter{}, entry.ID).Error
+}
+
+func setLogStatus(recipientID, status, errorMsg string) {
+	if recipientID == "" {
+		return
+	}
+	database.DB.Model(&models.SendLog{}).
+		Where("recipient_id = ?", recipientID).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"error_msg":  errorMsg,
+			"updated_at": time.Now(),
+		})
+}
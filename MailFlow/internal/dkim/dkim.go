@@ -0,0 +1,158 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+const (
+	AlgorithmRSA     = "rsa"
+	AlgorithmEd25519 = "ed25519"
+
+	DefaultRSABits = 2048
+	MinRSABits     = 1024
+	MaxRSABits     = 4096
+)
+
+var (
+	ErrInvalidKey = errors.New("无效的DKIM私钥，支持PEM格式的RSA或Ed25519私钥")
+
+	labelPattern = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]*[a-z0-9])?$`)
+)
+
+func Normalize(domain, selector string) (string, string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	selector = strings.ToLower(strings.TrimSpace(selector))
+	if !strings.Contains(domain, ".") || !validName(domain) {
+		return "", "", fmt.Errorf("无效的域名: %s", domain)
+	}
+	if !validName(selector) {
+		return "", "", fmt.Errorf("无效的selector: %s", selector)
+	}
+	return domain, selector, nil
+}
+
+func Generate(algorithm string, bits int) (string, error) {
+	var key crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmRSA:
+		if bits == 0 {
+			bits = DefaultRSABits
+		}
+		if bits < MinRSABits || bits > MaxRSABits {
+			return "", fmt.Errorf("RSA密钥长度必须在%d到%d之间", MinRSABits, MaxRSABits)
+		}
+		key, err = rsa.GenerateKey(rand.Reader, bits)
+	case AlgorithmEd25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("不支持的算法: %s", algorithm)
+	}
+	if err != nil {
+		return "", fmt.Errorf("生成密钥失败: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("生成密钥失败: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+func ParsePrivateKey(data string) (crypto.Signer, string, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(data)))
+	if block == nil {
+		return nil, "", ErrInvalidKey
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, "", ErrInvalidKey
+	}
+	if err != nil {
+		return nil, "", ErrInvalidKey
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < MinRSABits {
+			return nil, "", fmt.Errorf("RSA密钥长度不能小于%d位", MinRSABits)
+		}
+		return key, AlgorithmRSA, nil
+	case ed25519.PrivateKey:
+		return key, AlgorithmEd25519, nil
+	}
+	return nil, "", ErrInvalidKey
+}
+
+func PublicKey(signer crypto.Signer) (string, error) {
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(der), nil
+	case ed25519.PublicKey:
+		return base64.StdEncoding.EncodeToString(pub), nil
+	}
+	return "", ErrInvalidKey
+}
+
+func RecordName(key *models.DKIMKey) string {
+	return key.Selector + "._domainkey." + key.Domain
+}
+
+func Record(key *models.DKIMKey) string {
+	return fmt.Sprintf("v=DKIM1; k=%s; p=%s", key.Algorithm, key.PublicKey)
+}
+
+func Find(fromEmail string) (*models.DKIMKey, error) {
+	at := strings.LastIndex(fromEmail, "@")
+	if at < 0 {
+		return nil, nil
+	}
+	domain := strings.ToLower(fromEmail[at+1:])
+
+	var key models.DKIMKey
+	result := database.DB.Where("domain = ? AND is_active = ?", domain, true).
+		Order("created_at DESC").Limit(1).Find(&key)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+func validName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) > 63 || !labelPattern.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package dkim
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		domain   string
+		selector string
+		wantErr  bool
+	}{
+		{" Example.COM. ", "MF2024", false},
+		{"mail.example.com", "s_1", false},
+		{"localhost", "mf", true},
+		{"exa mple.com", "mf", true},
+		{"example.com", "", true},
+		{"example.com", "bad.", true},
+	}
+
+	for _, tt := range tests {
+		domain, selector, err := Normalize(tt.domain, tt.selector)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Normalize(%q, %q) error = %v, wantErr %v", tt.domain, tt.selector, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (domain != strings.TrimSuffix(strings.ToLower(strings.TrimSpace(tt.domain)), ".") || selector != strings.ToLower(tt.selector)) {
+			t.Errorf("Normalize(%q, %q) = (%q, %q)", tt.domain, tt.selector, domain, selector)
+		}
+	}
+}
+
+func TestGenerateAndParse(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRSA, AlgorithmEd25519} {
+		pemKey, err := Generate(algorithm, MinRSABits)
+		if err != nil {
+			t.Fatalf("Generate(%s): %v", algorithm, err)
+		}
+		signer, parsed, err := ParsePrivateKey(pemKey)
+		if err != nil || parsed != algorithm {
+			t.Fatalf("ParsePrivateKey(%s) = %s, %v", algorithm, parsed, err)
+		}
+		if pub, err := PublicKey(signer); err != nil || pub == "" {
+			t.Fatalf("PublicKey(%s) = %q, %v", algorithm, pub, err)
+		}
+	}
+
+	if _, err := Generate(AlgorithmRSA, 512); err == nil {
+		t.Fatal("short RSA keys must be rejected")
+	}
+	if _, err := Generate("dsa", 0); err == nil {
+		t.Fatal("unknown algorithms must be rejected")
+	}
+}
+
+func TestParsePrivateKeyInvalid(t *testing.T) {
+	for _, data := range []string{"", "not a key", "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"} {
+		if _, _, err := ParsePrivateKey(data); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("ParsePrivateKey(%q) error = %v, want ErrInvalidKey", data, err)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	key := &models.DKIMKey{Domain: "football.example.com", Selector: "brisbane", Algorithm: AlgorithmEd25519, PublicKey: rfcPublicKey}
+	if got := RecordName(key); got != "brisbane._domainkey.football.example.com" {
+		t.Fatalf("RecordName = %s", got)
+	}
+	if got := Record(key); got != "v=DKIM1; k=ed25519; p="+rfcPublicKey {
+		t.Fatalf("Record = %s", got)
+	}
+}
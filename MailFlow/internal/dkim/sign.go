@@ -0,0 +1,156 @@
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+var signedHeaders = []string{
+	"from", "reply-to", "subject", "date", "to", "cc", "message-id",
+	"in-reply-to", "references", "mime-version", "content-type", "content-transfer-encoding",
+	"list-id", "list-unsubscribe", "list-unsubscribe-post",
+}
+
+type headerField struct {
+	name string
+	raw  string
+}
+
+func Sign(message []byte, key *models.DKIMKey) ([]byte, error) {
+	signer, algorithm, err := ParsePrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header, body := splitMessage(message)
+	fields := parseHeader(header)
+
+	bodyHash := sha256.Sum256(canonicalBody(body))
+
+	names := make([]string, 0, len(signedHeaders))
+	var data bytes.Buffer
+	for _, name := range signedHeaders {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if fields[i].name == name {
+				names = append(names, name)
+				data.WriteString(canonicalHeader(fields[i].raw))
+				data.WriteString("\r\n")
+				break
+			}
+		}
+	}
+	if len(names) == 0 || names[0] != "from" {
+		return nil, fmt.Errorf("邮件缺少From头")
+	}
+
+	value := fmt.Sprintf("v=1; a=%s-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%d;\r\n\th=%s;\r\n\tbh=%s;\r\n\tb=",
+		algorithm, key.Domain, key.Selector, time.Now().Unix(),
+		strings.Join(names, ":"), base64.StdEncoding.EncodeToString(bodyHash[:]))
+	data.WriteString(canonicalHeader("DKIM-Signature: " + value))
+
+	digest := sha256.Sum256(data.Bytes())
+	var signature []byte
+	if algorithm == AlgorithmRSA {
+		signature, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	} else {
+		signature, err = signer.Sign(rand.Reader, digest[:], crypto.Hash(0))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DKIM签名失败: %w", err)
+	}
+
+	var signed bytes.Buffer
+	signed.Grow(len(message) + 1024)
+	signed.WriteString("DKIM-Signature: " + value + fold(base64.StdEncoding.EncodeToString(signature)) + "\r\n")
+	signed.Write(message)
+	return signed.Bytes(), nil
+}
+
+func splitMessage(message []byte) ([]byte, []byte) {
+	if i := bytes.Index(message, []byte("\r\n\r\n")); i >= 0 {
+		return message[:i+2], message[i+4:]
+	}
+	return message, nil
+}
+
+func parseHeader(header []byte) []headerField {
+	var fields []headerField
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].raw += line
+			continue
+		}
+		name := line
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			name = line[:i]
+		}
+		fields = append(fields, headerField{name: strings.ToLower(strings.TrimSpace(name)), raw: line})
+	}
+	for i := range fields {
+		fields[i].raw = strings.TrimSuffix(fields[i].raw, "\r\n")
+	}
+	return fields
+}
+
+func canonicalHeader(raw string) string {
+	name, value, _ := strings.Cut(raw, ":")
+	value = strings.NewReplacer("\r\n", "").Replace(value)
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.TrimSpace(collapseSpace(value))
+}
+
+func canonicalBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(collapseSpace(line), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func collapseSpace(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	space := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' || s[i] == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(s[i])
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func fold(value string) string {
+	var b strings.Builder
+	for len(value) > 72 {
+		b.WriteString(value[:72])
+		b.WriteString("\r\n\t")
+		value = value[72:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	Stream    = "mailflow:events"
+	MaxLength = 100000
+
+	KeyExpired = "key.expired"
+
+	EmailQueued       = "email.queued"
+	EmailSent         = "email.sent"
+	EmailFailed       = "email.failed"
+	EmailBounced      = "email.bounced"
+	EmailComplained   = "email.complained"
+	EmailSuppressed   = "email.suppressed"
+	EmailUnsubscribed = "email.unsubscribed"
+
+	SMTPDisabled  = "smtp.disabled"
+	SMTPRecovered = "smtp.recovered"
+)
+
+type Event struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	APIKeyID  uint                   `json:"api_key_id"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+func Publish(ctx context.Context, eventType string, apiKeyID uint, data map[string]interface{}) error {
+	event := Event{
+		Type:      eventType,
+		APIKeyID:  apiKeyID,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	err = queue.Client.XAdd(ctx, &redis.XAddArgs{
+		Stream: Stream,
+		MaxLen: MaxLength,
+		Approx: true,
+		Values: map[string]interface{}{"type": eventType, "payload": payload},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("发布事件失败: %w", err)
+	}
+	return nil
+}
+
+func EnsureGroup(ctx context.Context, group string) error {
+	err := queue.Client.XGroupCreateMkStream(ctx, Stream, group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("创建事件消费组失败: %w", err)
+	}
+	return nil
+}
+
+func ReadGroup(ctx context.Context, group, consumer, start string, count int64, block time.Duration) ([]Event, error) {
+	streams, err := queue.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{Stream, start},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取事件失败: %w", err)
+	}
+
+	var list []Event
+	for _, stream := range streams {
+		list = append(list, decode(stream.Messages)...)
+	}
+	return list, nil
+}
+
+func Claim(ctx context.Context, group, consumer string, minIdle time.Duration, start string, count int64) ([]Event, string, error) {
+	messages, next, err := queue.Client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   Stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    start,
+		Count:    count,
+	}).Result()
+	if err != nil {
+		return nil, "", fmt.Errorf("认领待处理事件失败: %w", err)
+	}
+	return decode(messages), next, nil
+}
+
+func Ack(ctx context.Context, group string, ids ...string) error {
+	return queue.Client.XAck(ctx, Stream, group, ids...).Err()
+}
+
+func List(ctx context.Context, after string, count int64) ([]Event, error) {
+	start := "-"
+	if after != "" {
+		start = "(" + after
+	}
+
+	messages, err := queue.Client.XRangeN(ctx, Stream, start, "+", count).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取事件失败: %w", err)
+	}
+
+	return decode(messages), nil
+}
+
+func decode(messages []redis.XMessage) []Event {
+	list := make([]Event, 0, len(messages))
+	for _, msg := range messages {
+		payload, _ := msg.Values["payload"].(string)
+
+		var event Event
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			event.Type, _ = msg.Values["type"].(string)
+		}
+		event.ID = msg.ID
+		list = append(list, event)
+	}
+	return list
+}
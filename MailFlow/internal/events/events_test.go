@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestClaim(t *testing.T) {
+	mr := miniredis.RunT(t)
+	queue.Client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { queue.Client.Close() })
+	ctx := context.Background()
+
+	if err := EnsureGroup(ctx, "webhooks"); err != nil {
+		t.Fatal(err)
+	}
+	for _, eventType := range []string{EmailSent, EmailBounced} {
+		if err := Publish(ctx, eventType, 7, map[string]interface{}{"to": "user@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := ReadGroup(ctx, "webhooks", "host:1", ">", 10, -1)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("ReadGroup = %d events, %v", len(list), err)
+	}
+	if list[0].Type != EmailSent || list[0].APIKeyID != 7 || list[0].ID == "" {
+		t.Fatalf("unexpected event: %+v", list[0])
+	}
+	if err := Ack(ctx, "webhooks", list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, next, err := Claim(ctx, "webhooks", "host:2", 0, "0-0", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != list[1].ID || claimed[0].Type != EmailBounced {
+		t.Fatalf("Claim = %+v, want only the unacknowledged event", claimed)
+	}
+	if next != "0-0" {
+		t.Fatalf("next = %q, want 0-0", next)
+	}
+
+	if list, err := ReadGroup(ctx, "webhooks", "host:2", "0", 10, 0); err != nil || len(list) != 1 {
+		t.Fatalf("claimed event must be pending on the new consumer: %d, %v", len(list), err)
+	}
+}
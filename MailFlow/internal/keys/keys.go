@@ -0,0 +1,45 @@
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const Prefix = "mf_live_"
+
+type Secret struct {
+	Plain  string
+	Hash   string
+	Prefix string
+	Last4  string
+}
+
+func Generate() (*Secret, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("生成API Key失败: %w", err)
+	}
+	return Parse(Prefix + hex.EncodeToString(buf)), nil
+}
+
+func Parse(plain string) *Secret {
+	visible := 8
+	if strings.HasPrefix(plain, Prefix) {
+		visible = len(Prefix) + 4
+	}
+
+	return &Secret{
+		Plain:  plain,
+		Hash:   Hash(plain),
+		Prefix: plain[:min(visible, len(plain))],
+		Last4:  plain[max(len(plain)-4, 0):],
+	}
+}
+
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
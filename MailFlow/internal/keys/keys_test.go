@@ -0,0 +1,62 @@
+package keys
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	const want = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got := Hash("hello"); got != want {
+		t.Fatalf("Hash(hello) = %s, want %s", got, want)
+	}
+	if Hash("mf_live_a") == Hash("mf_live_b") {
+		t.Fatal("different keys must not share a hash")
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		plain  string
+		prefix string
+		last4  string
+	}{
+		{"mf_live_0123456789abcdef", "mf_live_0123", "cdef"},
+		{"sk-legacy-key-value", "sk-legac", "alue"},
+		{"abc", "abc", "abc"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		secret := Parse(tt.plain)
+		if secret.Plain != tt.plain || secret.Hash != Hash(tt.plain) {
+			t.Errorf("Parse(%q) = %+v", tt.plain, secret)
+		}
+		if secret.Prefix != tt.prefix {
+			t.Errorf("Parse(%q).Prefix = %q, want %q", tt.plain, secret.Prefix, tt.prefix)
+		}
+		if secret.Last4 != tt.last4 {
+			t.Errorf("Parse(%q).Last4 = %q, want %q", tt.plain, secret.Last4, tt.last4)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		secret, err := Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(secret.Plain, Prefix) || len(secret.Plain) != len(Prefix)+48 {
+			t.Fatalf("unexpected key format: %q", secret.Plain)
+		}
+		if secret.Hash != Hash(secret.Plain) || strings.Contains(secret.Hash, secret.Plain) {
+			t.Fatalf("hash mismatch for %q", secret.Plain)
+		}
+		if seen[secret.Plain] {
+			t.Fatalf("duplicate key generated: %q", secret.Plain)
+		}
+		seen[secret.Plain] = true
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,7 +18,7 @@ var (
 	roundRobinIdx = make(map[int]int)
 )
 
-func SelectSMTP(ctx context.Context) (*models.SMTPConfig, error) {
+func SelectSMTP(ctx context.Context, domain string) (*models.SMTPConfig, error) {
 	var configs []models.SMTPConfig
 	if err := database.DB.Where("status = ?", "active").Order("priority DESC").Find(&configs).Error; err != nil {
 		return nil, fmt.Errorf("查询SMTP配置失败: %w", err)
@@ -27,6 +28,13 @@ func SelectSMTP(ctx context.Context) (*models.SMTPConfig, error) {
 		return nil, fmt.Errorf("没有可用的SMTP配置")
 	}
 
+	if domain != "" {
+		configs = filterByDomain(configs, domain)
+		if len(configs) == 0 {
+			return nil, fmt.Errorf("没有允许发送域名 %s 的SMTP配置", domain)
+		}
+	}
+
 	grouped := groupByPriority(configs)
 	
 	for _, priority := range getSortedPriorities(grouped) {
@@ -49,6 +57,30 @@ func SelectSMTP(ctx context.Context) (*models.SMTPConfig, error) {
 	return nil, fmt.Errorf("所有SMTP服务器都已达到小时限额")
 }
 
+func filterByDomain(configs []models.SMTPConfig, domain string) []models.SMTPConfig {
+	filtered := make([]models.SMTPConfig, 0, len(configs))
+	for _, config := range configs {
+		if AllowsDomain(&config, domain) {
+			filtered = append(filtered, config)
+		}
+	}
+	return filtered
+}
+
+func AllowsDomain(config *models.SMTPConfig, domain string) bool {
+	domain = strings.ToLower(domain)
+	if at := strings.LastIndex(config.FromEmail, "@"); at >= 0 && strings.ToLower(config.FromEmail[at+1:]) == domain {
+		return true
+	}
+	for _, allowed := range strings.Split(config.AllowedDomains, ",") {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "*" || allowed == domain {
+			return true
+		}
+	}
+	return false
+}
+
 func groupByPriority(configs []models.SMTPConfig) map[int][]models.SMTPConfig {
 	grouped := make(map[int][]models.SMTPConfig)
 	for _, config := range configs {
@@ -7,36 +7,61 @@ import (
 )
 
 type Plan struct {
-	ID           uint      `gorm:"primarykey" json:"id"`
-	Code         string    `gorm:"uniqueIndex;not null" json:"code"`
-	Name         string    `gorm:"not null" json:"name"`
-	Description  string    `json:"description"`
-	MinuteLimit  int       `gorm:"default:0" json:"minute_limit"`
-	DailyLimit   int       `gorm:"default:0" json:"daily_limit"`
-	WeeklyLimit  int       `gorm:"default:0" json:"weekly_limit"`
-	MonthlyLimit int       `gorm:"default:0" json:"monthly_limit"`
-	IsActive     bool      `gorm:"default:true" json:"is_active"`
-	SortOrder    int       `gorm:"default:0" json:"sort_order"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                 uint      `gorm:"primarykey" json:"id"`
+	Code               string    `gorm:"uniqueIndex;not null" json:"code"`
+	Name               string    `gorm:"not null" json:"name"`
+	Description        string    `json:"description"`
+	MinuteLimit        int       `gorm:"default:0" json:"minute_limit"`
+	DailyLimit         int       `gorm:"default:0" json:"daily_limit"`
+	WeeklyLimit        int       `gorm:"default:0" json:"weekly_limit"`
+	MonthlyLimit       int       `gorm:"default:0" json:"monthly_limit"`
+	MaxAttachmentSize  int       `gorm:"default:0" json:"max_attachment_size"`
+	RateLimitAlgorithm string    `gorm:"default:fixed" json:"rate_limit_algorithm"`
+	BurstLimit         int       `gorm:"default:0" json:"burst_limit"`
+	IsActive           bool      `gorm:"default:true" json:"is_active"`
+	SortOrder          int       `gorm:"default:0" json:"sort_order"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 type APIKey struct {
-	ID           uint      `gorm:"primarykey" json:"id"`
-	Key          string    `gorm:"uniqueIndex;not null" json:"key"`
-	Name         string    `gorm:"not null" json:"name"`
-	PlanID       *uint     `gorm:"index" json:"plan_id"`
-	Plan         string    `gorm:"default:basic" json:"plan"`
-	IsCustom     bool      `gorm:"default:false" json:"is_custom"`
-	MinuteLimit  int       `gorm:"default:100" json:"minute_limit"`
-	DailyLimit   int       `gorm:"default:10000" json:"daily_limit"`
-	WeeklyLimit  int       `gorm:"default:50000" json:"weekly_limit"`
-	MonthlyLimit int       `gorm:"default:200000" json:"monthly_limit"`
-	TotalLimit   int       `gorm:"default:0" json:"total_limit"`
-	TotalUsed    int       `gorm:"default:0" json:"total_used"`
-	Status       string    `gorm:"default:active" json:"status"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                   uint       `gorm:"primarykey" json:"id"`
+	KeyHash              string     `gorm:"uniqueIndex;not null" json:"-"`
+	KeyPrefix            string     `gorm:"index" json:"key_prefix"`
+	KeyLast4             string     `json:"key_last4"`
+	PreviousKeyHash      string     `gorm:"index" json:"-"`
+	PreviousKeyExpiresAt *time.Time `json:"previous_key_expires_at"`
+	RotatedAt            *time.Time `json:"rotated_at"`
+	StartsAt             *time.Time `json:"starts_at"`
+	ExpiresAt            *time.Time `gorm:"index" json:"expires_at"`
+	AllowedIPs           string     `json:"allowed_ips"`
+	AllowedOrigins       string     `json:"allowed_origins"`
+	Scopes               string     `json:"scopes"`
+	Name                 string     `gorm:"not null" json:"name"`
+	PlanID               *uint      `gorm:"index" json:"plan_id"`
+	Plan                 string     `gorm:"default:basic" json:"plan"`
+	IsCustom             bool       `gorm:"default:false" json:"is_custom"`
+	MinuteLimit          int        `gorm:"default:100" json:"minute_limit"`
+	DailyLimit           int        `gorm:"default:10000" json:"daily_limit"`
+	WeeklyLimit          int        `gorm:"default:50000" json:"weekly_limit"`
+	MonthlyLimit         int        `gorm:"default:200000" json:"monthly_limit"`
+	TotalLimit           int        `gorm:"default:0" json:"total_limit"`
+	RateLimitAlgorithm   string     `gorm:"default:fixed" json:"rate_limit_algorithm"`
+	BurstLimit           int        `gorm:"default:0" json:"burst_limit"`
+	TotalUsed            int        `gorm:"default:0" json:"total_used"`
+	Status               string     `gorm:"default:active" json:"status"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+type SenderIdentity struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	APIKeyID  uint      `gorm:"uniqueIndex:idx_identity_key_email;not null" json:"api_key_id"`
+	Email     string    `gorm:"uniqueIndex:idx_identity_key_email;not null" json:"email"`
+	Name      string    `json:"name"`
+	Verified  bool      `gorm:"default:false" json:"verified"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type SMTPConfig struct {
@@ -50,6 +75,7 @@ type SMTPConfig struct {
 	Encryption     string     `gorm:"default:starttls" json:"encryption"`
 	FromEmail      string     `gorm:"not null" json:"from_email"`
 	FromName       string     `json:"from_name"`
+	AllowedDomains string     `json:"allowed_domains"`
 	MaxPerHour     int        `gorm:"default:100" json:"max_per_hour"`
 	MaxPerDay      int        `gorm:"default:0" json:"max_per_day"`
 	Priority       int        `gorm:"default:1" json:"priority"`
@@ -58,19 +84,33 @@ type SMTPConfig struct {
 	LastFailedAt   *time.Time `json:"last_failed_at"`
 	LastCheckedAt  *time.Time `json:"last_checked_at"`
 	AutoRecoverAt  *time.Time `json:"auto_recover_at"`
+	ReturnPath     string     `json:"return_path"`
+	BounceProtocol string     `json:"bounce_protocol"`
+	BounceHost     string     `json:"bounce_host"`
+	BouncePort     int        `json:"bounce_port"`
+	BounceSecurity string     `gorm:"default:ssl" json:"bounce_security"`
+	BounceUsername string     `json:"bounce_username"`
+	BouncePassword string     `json:"bounce_password"`
+	BounceMailbox  string     `json:"bounce_mailbox"`
+	BouncePolledAt *time.Time `json:"bounce_polled_at"`
+	BounceError    string     `json:"bounce_error"`
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 type SendLog struct {
 	ID           uint      `gorm:"primarykey" json:"id"`
+	MessageID    string    `gorm:"index" json:"message_id"`
+	RecipientID  string    `gorm:"index" json:"recipient_id"`
 	APIKeyID     uint      `gorm:"index" json:"api_key_id"`
 	To           string    `gorm:"not null" json:"to"`
 	Subject      string    `json:"subject"`
 	Status       string    `gorm:"index" json:"status"`
 	ErrorMsg     string    `json:"error_msg"`
 	SMTPConfigID uint      `json:"smtp_config_id"`
+	Attempt      int       `gorm:"default:0" json:"attempt"`
 	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
 
 type UsageStats struct {
@@ -91,25 +131,141 @@ type SMTPStats struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+type AdminUser struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	Username       string     `gorm:"uniqueIndex;not null" json:"username"`
+	PasswordHash   string     `gorm:"not null" json:"-"`
+	Role           string     `gorm:"not null;default:readonly" json:"role"`
+	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	TOTPSecret     string     `json:"-"`
+	TOTPEnabled    bool       `gorm:"default:false" json:"totp_enabled"`
+	TOTPLastStep   int64      `json:"-"`
+	RecoveryCodes  string     `gorm:"type:text" json:"-"`
+	SessionVersion int        `gorm:"default:0" json:"-"`
+	LastLoginAt    *time.Time `json:"last_login_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
 type AdminToken struct {
 	ID          uint       `gorm:"primarykey" json:"id"`
 	Token       string     `gorm:"uniqueIndex;not null" json:"token"`
 	Name        string     `gorm:"not null" json:"name"`
 	Description string     `json:"description"`
+	UserID      *uint      `gorm:"index" json:"user_id"`
+	Role        string     `gorm:"not null;default:readonly" json:"role"`
 	IsActive    bool       `gorm:"default:true" json:"is_active"`
 	LastUsedAt  *time.Time `json:"last_used_at"`
 	CreatedAt   time.Time  `json:"created_at"`
 }
 
+type Template struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	APIKeyID  *uint     `gorm:"index" json:"api_key_id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Subject   string    `gorm:"not null" json:"subject"`
+	HTML      string    `gorm:"type:text" json:"html"`
+	Text      string    `gorm:"type:text" json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type DeadLetter struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	MessageID   string    `gorm:"index" json:"message_id"`
+	RecipientID string    `gorm:"index" json:"recipient_id"`
+	APIKeyID    uint      `gorm:"index" json:"api_key_id"`
+	To          string    `gorm:"not null" json:"to"`
+	Subject     string    `json:"subject"`
+	Attempts    int       `gorm:"default:0" json:"attempts"`
+	LastError   string    `json:"last_error"`
+	Errors      string    `gorm:"type:text" json:"errors,omitempty"`
+	Payload     string    `gorm:"type:text;not null" json:"payload,omitempty"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+type Webhook struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	APIKeyID    uint      `gorm:"index;not null" json:"api_key_id"`
+	URL         string    `gorm:"not null" json:"url"`
+	Secret      string    `gorm:"not null" json:"-"`
+	Events      string    `json:"events"`
+	Description string    `json:"description"`
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	WebhookID      uint       `gorm:"uniqueIndex:idx_webhook_event;not null" json:"webhook_id"`
+	APIKeyID       uint       `gorm:"index" json:"api_key_id"`
+	EventID        string     `gorm:"uniqueIndex:idx_webhook_event;not null" json:"event_id"`
+	EventType      string     `gorm:"index" json:"event_type"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Status         string     `gorm:"index;default:pending" json:"status"`
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type WebhookAttempt struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	DeliveryID uint      `gorm:"index;not null" json:"delivery_id"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error"`
+	Response   string    `gorm:"type:text" json:"response"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Suppression struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	APIKeyID  uint       `gorm:"uniqueIndex:idx_suppression_scope_address;not null;default:0" json:"api_key_id"`
+	Address   string     `gorm:"uniqueIndex:idx_suppression_scope_address;not null" json:"address"`
+	Type      string     `gorm:"not null;default:address" json:"type"`
+	Reason    string     `gorm:"index" json:"reason"`
+	Source    string     `json:"source"`
+	Detail    string     `gorm:"type:text" json:"detail"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type DKIMKey struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	Domain     string    `gorm:"uniqueIndex:idx_dkim_domain_selector;not null" json:"domain"`
+	Selector   string    `gorm:"uniqueIndex:idx_dkim_domain_selector;not null" json:"selector"`
+	Algorithm  string    `gorm:"not null" json:"algorithm"`
+	PrivateKey string    `gorm:"type:text;not null" json:"-"`
+	PublicKey  string    `gorm:"type:text;not null" json:"public_key"`
+	IsActive   bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
 func AutoMigrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&Plan{},
 		&APIKey{},
+		&SenderIdentity{},
 		&SMTPConfig{},
 		&SendLog{},
 		&UsageStats{},
 		&SMTPStats{},
+		&AdminUser{},
 		&AdminToken{},
+		&DeadLetter{},
+		&Template{},
+		&Webhook{},
+		&WebhookDelivery{},
+		&WebhookAttempt{},
+		&Suppression{},
+		&DKIMKey{},
 	)
 }
-
@@ -5,22 +5,139 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/mailflow/smtp-loadbalancer/internal/config"
 	"github.com/redis/go-redis/v9"
 )
 
-const QueueKey = "mailflow:email_queue"
+const (
+	QueueKey            = "mailflow:email_queue"
+	ProcessingKeyPrefix = "mailflow:processing:"
+	HeartbeatKeyPrefix  = "mailflow:heartbeat:"
+	ConsumersKey        = "mailflow:consumers"
+	HeartbeatTTL        = 30 * time.Second
+	ReapInterval        = 15 * time.Second
+	BlobKeyPrefix       = "mailflow:blob:"
+	BlobTTL             = 7 * 24 * time.Hour
+	ScheduledKey        = "mailflow:scheduled"
+	ScheduledTasksKey   = "mailflow:scheduled:tasks"
+	PromoteInterval     = 1 * time.Second
+	PromoteBatchSize    = 100
+	MaxScheduleDelay    = 30 * 24 * time.Hour
+
+	CategoryTransactional = "transactional"
+	CategoryMarketing     = "marketing"
+)
+
+var promoteScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(ids) do
+	if redis.call('ZREM', KEYS[1], id) == 1 then
+		local data = redis.call('HGET', KEYS[2], id)
+		redis.call('HDEL', KEYS[2], id)
+		if data then
+			redis.call('LPUSH', KEYS[3], data)
+		end
+	end
+end
+return #ids
+`)
+
+var cancelScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 1 then
+	local data = redis.call('HGET', KEYS[2], ARGV[1])
+	redis.call('HDEL', KEYS[2], ARGV[1])
+	return data or ''
+end
+return false
+`)
 
 var Client *redis.Client
 
+type Recipient struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Type  string `json:"type,omitempty"`
+}
+
+type AttemptError struct {
+	Attempt int       `json:"attempt"`
+	SMTP    string    `json:"smtp,omitempty"`
+	Error   string    `json:"error"`
+	At      time.Time `json:"at"`
+}
+
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+	Size        int    `json:"size"`
+	BlobKey     string `json:"blob_key"`
+}
+
 type EmailTask struct {
-	APIKeyID uint     `json:"api_key_id"`
-	To       []string `json:"to"`
-	Subject  string   `json:"subject"`
-	HTML     string   `json:"html"`
-	Text     string   `json:"text"`
+	MessageID  string      `json:"message_id"`
+	APIKeyID   uint        `json:"api_key_id"`
+	FromEmail  string      `json:"from_email,omitempty"`
+	FromName   string      `json:"from_name,omitempty"`
+	To         []string    `json:"to"`
+	Cc         []string    `json:"cc,omitempty"`
+	Bcc        []string    `json:"bcc,omitempty"`
+	Recipients []Recipient `json:"recipients"`
+	Subject    string      `json:"subject"`
+	HTML       string      `json:"html"`
+	Text       string      `json:"text"`
+
+	ReplyTo     string            `json:"reply_to,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Attachments []Attachment      `json:"attachments,omitempty"`
+	Category    string            `json:"category,omitempty"`
+
+	CreatedAt       time.Time      `json:"created_at"`
+	QuotaReservedAt *time.Time     `json:"quota_reserved_at,omitempty"`
+	Attempt         int            `json:"attempt,omitempty"`
+	Errors          []AttemptError `json:"errors,omitempty"`
+
+	raw string
+}
+
+func (t *EmailTask) AssignIDs() {
+	if t.MessageID == "" {
+		t.MessageID = uuid.New().String()
+	}
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	if len(t.Recipients) == 0 {
+		t.addRecipients(t.To, "to")
+		t.addRecipients(t.Cc, "cc")
+		t.addRecipients(t.Bcc, "bcc")
+	}
+}
+
+func (t *EmailTask) addRecipients(emails []string, recipientType string) {
+	for _, email := range emails {
+		t.Recipients = append(t.Recipients, Recipient{
+			ID:    uuid.New().String(),
+			Email: email,
+			Type:  recipientType,
+		})
+	}
+}
+
+func (t *EmailTask) SharedHeaders() bool {
+	return len(t.Cc) > 0 || len(t.Bcc) > 0
+}
+
+func (t *EmailTask) FromDomain() string {
+	if at := strings.LastIndex(t.FromEmail, "@"); at >= 0 {
+		return strings.ToLower(t.FromEmail[at+1:])
+	}
+	return ""
 }
 
 func Connect(cfg *config.RedisConfig) error {
@@ -50,8 +167,112 @@ func PushEmail(ctx context.Context, task *EmailTask) error {
 	return Client.LPush(ctx, QueueKey, data).Err()
 }
 
-func PopEmail(ctx context.Context, timeout time.Duration) (*EmailTask, error) {
-	result, err := Client.BRPop(ctx, timeout, QueueKey).Result()
+func EnqueueBatch(ctx context.Context, tasks []*EmailTask, sendAts []time.Time) error {
+	pipe := Client.TxPipeline()
+	for i, task := range tasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return fmt.Errorf("序列化邮件任务失败: %w", err)
+		}
+
+		if sendAts[i].IsZero() {
+			pipe.LPush(ctx, QueueKey, data)
+			continue
+		}
+		pipe.HSet(ctx, ScheduledTasksKey, task.MessageID, data)
+		pipe.ZAdd(ctx, ScheduledKey, redis.Z{Score: float64(sendAts[i].Unix()), Member: task.MessageID})
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func StoreBlob(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return Client.Set(ctx, BlobKeyPrefix+key, data, ttl).Err()
+}
+
+func DeleteBlobs(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = BlobKeyPrefix + key
+	}
+	return Client.Del(ctx, prefixed...).Err()
+}
+
+func LoadBlob(ctx context.Context, key string) ([]byte, error) {
+	data, err := Client.Get(ctx, BlobKeyPrefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("附件已过期或不存在: %s", key)
+	}
+	return data, err
+}
+
+func ScheduleEmail(ctx context.Context, id string, task *EmailTask, at time.Time) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("序列化邮件任务失败: %w", err)
+	}
+
+	pipe := Client.TxPipeline()
+	pipe.HSet(ctx, ScheduledTasksKey, id, data)
+	pipe.ZAdd(ctx, ScheduledKey, redis.Z{Score: float64(at.Unix()), Member: id})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func CancelScheduled(ctx context.Context, id string) (*EmailTask, error) {
+	data, err := cancelScript.Run(ctx, Client, []string{ScheduledKey, ScheduledTasksKey}, id).Text()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var task EmailTask
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &task); err != nil {
+			return nil, fmt.Errorf("反序列化邮件任务失败: %w", err)
+		}
+	}
+	return &task, nil
+}
+
+func StartScheduler(ctx context.Context) {
+	ticker := time.NewTicker(PromoteInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			promoteDueTasks(ctx)
+		}
+	}
+}
+
+func promoteDueTasks(ctx context.Context) {
+	for {
+		now := strconv.FormatInt(time.Now().Unix(), 10)
+		count, err := promoteScript.Run(ctx, Client, []string{ScheduledKey, ScheduledTasksKey, QueueKey}, now, PromoteBatchSize).Int()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("转移到期定时任务失败: %v", err)
+			}
+			return
+		}
+		if count < PromoteBatchSize {
+			return
+		}
+	}
+}
+
+func PopEmail(ctx context.Context, consumer string, timeout time.Duration) (*EmailTask, error) {
+	result, err := Client.BRPopLPush(ctx, QueueKey, processingKey(consumer), timeout).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil
@@ -59,18 +280,92 @@ func PopEmail(ctx context.Context, timeout time.Duration) (*EmailTask, error) {
 		return nil, err
 	}
 
-	if len(result) < 2 {
-		return nil, fmt.Errorf("无效的队列数据")
-	}
-
 	var task EmailTask
-	if err := json.Unmarshal([]byte(result[1]), &task); err != nil {
+	if err := json.Unmarshal([]byte(result), &task); err != nil {
+		Client.LRem(ctx, processingKey(consumer), 1, result)
 		return nil, fmt.Errorf("反序列化邮件任务失败: %w", err)
 	}
+	task.raw = result
 
 	return &task, nil
 }
 
+func AckEmail(ctx context.Context, consumer string, task *EmailTask) error {
+	if task.raw == "" {
+		return nil
+	}
+	return Client.LRem(ctx, processingKey(consumer), 1, task.raw).Err()
+}
+
+func RegisterConsumer(ctx context.Context, consumer string) error {
+	if err := Client.SAdd(ctx, ConsumersKey, consumer).Err(); err != nil {
+		return err
+	}
+	return Heartbeat(ctx, consumer)
+}
+
+func Heartbeat(ctx context.Context, consumer string) error {
+	return Client.Set(ctx, HeartbeatKeyPrefix+consumer, time.Now().Unix(), HeartbeatTTL).Err()
+}
+
+func StartReaper(ctx context.Context) {
+	ticker := time.NewTicker(ReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reapDeadConsumers(ctx)
+		}
+	}
+}
+
+func reapDeadConsumers(ctx context.Context) {
+	consumers, err := Client.SMembers(ctx, ConsumersKey).Result()
+	if err != nil {
+		log.Printf("获取队列消费者失败: %v", err)
+		return
+	}
+
+	for _, consumer := range consumers {
+		alive, err := Client.Exists(ctx, HeartbeatKeyPrefix+consumer).Result()
+		if err != nil || alive > 0 {
+			continue
+		}
+
+		count, err := requeueProcessing(ctx, consumer)
+		if err != nil {
+			log.Printf("回收消费者[%s]的任务失败: %v", consumer, err)
+			continue
+		}
+
+		Client.SRem(ctx, ConsumersKey, consumer)
+		if count > 0 {
+			log.Printf("消费者[%s]心跳超时，已将 %d 个未完成任务重新入队", consumer, count)
+		}
+	}
+}
+
+func requeueProcessing(ctx context.Context, consumer string) (int, error) {
+	count := 0
+	for {
+		err := Client.RPopLPush(ctx, processingKey(consumer), QueueKey).Err()
+		if err == redis.Nil {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func processingKey(consumer string) string {
+	return ProcessingKeyPrefix + consumer
+}
+
 func Close() error {
 	if Client != nil {
 		return Client.Close()
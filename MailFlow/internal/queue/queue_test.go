@@ -0,0 +1,278 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func setup(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	Client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { Client.Close() })
+	return mr
+}
+
+func push(t *testing.T, subject string) {
+	t.Helper()
+	task := &EmailTask{To: []string{"user@example.org"}, Subject: subject}
+	if err := PushEmail(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pop(t *testing.T, consumer string) *EmailTask {
+	t.Helper()
+	task, err := PopEmail(context.Background(), consumer, time.Second)
+	if err != nil {
+		t.Fatalf("PopEmail: %v", err)
+	}
+	return task
+}
+
+func length(t *testing.T, key string) int64 {
+	t.Helper()
+	n, err := Client.LLen(context.Background(), key).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestPopAndAck(t *testing.T) {
+	setup(t)
+	ctx := context.Background()
+	push(t, "first")
+	push(t, "second")
+
+	task := pop(t, "c1")
+	if task == nil || task.Subject != "first" {
+		t.Fatalf("PopEmail = %+v, want the oldest task", task)
+	}
+	if length(t, QueueKey) != 1 || length(t, processingKey("c1")) != 1 {
+		t.Fatal("popped task must move to the consumer's processing list")
+	}
+
+	if err := AckEmail(ctx, "c1", task); err != nil {
+		t.Fatal(err)
+	}
+	if length(t, processingKey("c1")) != 0 {
+		t.Fatal("acked task must leave the processing list")
+	}
+	if length(t, QueueKey) != 1 {
+		t.Fatal("ack must not touch the pending queue")
+	}
+}
+
+func TestPopEmpty(t *testing.T) {
+	setup(t)
+	if task := pop(t, "c1"); task != nil {
+		t.Fatalf("PopEmail on empty queue = %+v", task)
+	}
+}
+
+func TestPopInvalidPayload(t *testing.T) {
+	setup(t)
+	Client.LPush(context.Background(), QueueKey, "not json")
+
+	if _, err := PopEmail(context.Background(), "c1", time.Second); err == nil {
+		t.Fatal("invalid payload must return an error")
+	}
+	if length(t, processingKey("c1")) != 0 {
+		t.Fatal("invalid payload must be dropped from the processing list")
+	}
+}
+
+func TestAckAfterTaskChanged(t *testing.T) {
+	setup(t)
+	ctx := context.Background()
+	push(t, "hello")
+
+	task := pop(t, "c1")
+	task.AssignIDs()
+	task.Attempt = 3
+	task.Errors = append(task.Errors, AttemptError{Attempt: 3, Error: "timeout", At: time.Now()})
+
+	if err := AckEmail(ctx, "c1", task); err != nil {
+		t.Fatal(err)
+	}
+	if length(t, processingKey("c1")) != 0 {
+		t.Fatal("ack must remove the original payload even after the task was modified")
+	}
+
+	if err := AckEmail(ctx, "c1", &EmailTask{Subject: "never popped"}); err != nil {
+		t.Fatalf("acking a task that was not popped must be a no-op: %v", err)
+	}
+}
+
+func TestReapDeadConsumer(t *testing.T) {
+	mr := setup(t)
+	ctx := context.Background()
+	for _, consumer := range []string{"dead", "alive"} {
+		if err := RegisterConsumer(ctx, consumer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	push(t, "one")
+	push(t, "two")
+	push(t, "three")
+
+	pop(t, "dead")
+	pop(t, "dead")
+	pop(t, "alive")
+
+	mr.FastForward(HeartbeatTTL / 2)
+	if err := Heartbeat(ctx, "alive"); err != nil {
+		t.Fatal(err)
+	}
+	reapDeadConsumers(ctx)
+	if length(t, processingKey("dead")) != 2 {
+		t.Fatal("consumers with a live heartbeat must not be reaped")
+	}
+
+	mr.FastForward(HeartbeatTTL/2 + time.Second)
+	reapDeadConsumers(ctx)
+
+	if length(t, processingKey("dead")) != 0 || length(t, QueueKey) != 2 {
+		t.Fatalf("dead consumer's tasks must be requeued: queue=%d", length(t, QueueKey))
+	}
+	if length(t, processingKey("alive")) != 1 {
+		t.Fatal("live consumer's tasks must stay in its processing list")
+	}
+	if member, _ := Client.SIsMember(ctx, ConsumersKey, "dead").Result(); member {
+		t.Fatal("dead consumer must be unregistered")
+	}
+	if member, _ := Client.SIsMember(ctx, ConsumersKey, "alive").Result(); !member {
+		t.Fatal("live consumer must stay registered")
+	}
+
+	first, second := pop(t, "alive"), pop(t, "alive")
+	if first == nil || second == nil || first.Subject != "one" || second.Subject != "two" {
+		t.Fatalf("requeued tasks must be picked up again in order: %+v %+v", first, second)
+	}
+}
+
+func promote(t *testing.T, now time.Time) int {
+	t.Helper()
+	count, err := promoteScript.Run(context.Background(), Client, []string{ScheduledKey, ScheduledTasksKey, QueueKey}, now.Unix(), PromoteBatchSize).Int()
+	if err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	return count
+}
+
+func schedule(t *testing.T, subject string, at time.Time) *EmailTask {
+	t.Helper()
+	task := &EmailTask{To: []string{"user@example.org"}, Subject: subject}
+	task.AssignIDs()
+	if err := ScheduleEmail(context.Background(), task.MessageID, task, at); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestPromoteScheduled(t *testing.T) {
+	setup(t)
+	sendAt := time.Unix(1700000000, 0)
+	task := schedule(t, "later", sendAt)
+
+	if count := promote(t, sendAt.Add(-time.Second)); count != 0 || length(t, QueueKey) != 0 {
+		t.Fatal("task must not be promoted before send_at")
+	}
+	if count := promote(t, sendAt); count != 1 {
+		t.Fatalf("promote at send_at = %d, want 1", count)
+	}
+
+	promoted := pop(t, "c1")
+	if promoted == nil || promoted.MessageID != task.MessageID || promoted.Subject != "later" {
+		t.Fatalf("promoted task = %+v", promoted)
+	}
+	if n, _ := Client.ZCard(context.Background(), ScheduledKey).Result(); n != 0 {
+		t.Fatal("promoted task must leave the schedule")
+	}
+	if n, _ := Client.HLen(context.Background(), ScheduledTasksKey).Result(); n != 0 {
+		t.Fatal("promoted task payload must be removed")
+	}
+
+	if count := promote(t, sendAt.Add(time.Hour)); count != 0 || length(t, QueueKey) != 0 {
+		t.Fatal("a task must only be promoted once")
+	}
+}
+
+func TestPromoteAfterSendAt(t *testing.T) {
+	setup(t)
+	now := time.Now()
+	schedule(t, "overdue", now.Add(-time.Hour))
+	schedule(t, "due", now)
+	schedule(t, "future", now.Add(time.Hour))
+
+	promoteDueTasks(context.Background())
+
+	if length(t, QueueKey) != 2 {
+		t.Fatalf("queue length = %d, want 2 due tasks", length(t, QueueKey))
+	}
+	if n, _ := Client.ZCard(context.Background(), ScheduledKey).Result(); n != 1 {
+		t.Fatal("future task must stay scheduled")
+	}
+}
+
+func TestEnqueueBatchScheduled(t *testing.T) {
+	setup(t)
+	now := time.Unix(1700000000, 0)
+	immediate := &EmailTask{MessageID: "m1", Subject: "now"}
+	scheduled := &EmailTask{MessageID: "m2", Subject: "later"}
+
+	if err := EnqueueBatch(context.Background(), []*EmailTask{immediate, scheduled}, []time.Time{{}, now}); err != nil {
+		t.Fatal(err)
+	}
+	if length(t, QueueKey) != 1 {
+		t.Fatal("task without send_at must be queued immediately")
+	}
+	if score, err := Client.ZScore(context.Background(), ScheduledKey, "m2").Result(); err != nil || int64(score) != now.Unix() {
+		t.Fatalf("scheduled score = %v, %v", score, err)
+	}
+}
+
+func TestCancelScheduled(t *testing.T) {
+	setup(t)
+	ctx := context.Background()
+	sendAt := time.Unix(1700000000, 0)
+	task := schedule(t, "cancel me", sendAt)
+
+	cancelled, err := CancelScheduled(ctx, task.MessageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled == nil || cancelled.MessageID != task.MessageID || cancelled.Subject != "cancel me" {
+		t.Fatalf("CancelScheduled = %+v", cancelled)
+	}
+	if count := promote(t, sendAt.Add(time.Hour)); count != 0 || length(t, QueueKey) != 0 {
+		t.Fatal("cancelled task must never be promoted")
+	}
+
+	if again, err := CancelScheduled(ctx, task.MessageID); err != nil || again != nil {
+		t.Fatalf("second cancel = %+v, %v, want nil", again, err)
+	}
+	if unknown, err := CancelScheduled(ctx, "missing"); err != nil || unknown != nil {
+		t.Fatalf("cancel unknown = %+v, %v, want nil", unknown, err)
+	}
+}
+
+func TestCancelAfterPromotion(t *testing.T) {
+	setup(t)
+	sendAt := time.Unix(1700000000, 0)
+	task := schedule(t, "too late", sendAt)
+	promote(t, sendAt)
+
+	cancelled, err := CancelScheduled(context.Background(), task.MessageID)
+	if err != nil || cancelled != nil {
+		t.Fatalf("cancel after promotion = %+v, %v, want nil so the API reports a conflict", cancelled, err)
+	}
+	if length(t, QueueKey) != 1 {
+		t.Fatal("cancel after promotion must leave the queued task alone")
+	}
+}
@@ -0,0 +1,212 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	AlgorithmFixed       = "fixed"
+	AlgorithmSliding     = "sliding"
+	AlgorithmTokenBucket = "token_bucket"
+
+	Window = 60 * time.Second
+)
+
+var fixedScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local consume = ARGV[3] == '1'
+local window = tonumber(ARGV[4])
+
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	ttl = window
+end
+
+if used + n > limit then
+	return {0, limit - used, ttl, ttl}
+end
+
+if consume then
+	redis.call('INCRBY', KEYS[1], n)
+	if redis.call('PTTL', KEYS[1]) < 0 then
+		redis.call('PEXPIRE', KEYS[1], window)
+	end
+	used = used + n
+end
+return {1, limit - used, ttl, 0}
+`)
+
+var slidingScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local consume = ARGV[3] == '1'
+local window = tonumber(ARGV[4])
+local now = tonumber(ARGV[5])
+local nonce = ARGV[6]
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local used = redis.call('ZCARD', KEYS[1])
+
+local reset = window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if #oldest > 0 then
+	reset = tonumber(oldest[2]) + window - now
+end
+
+if used + n > limit then
+	local retry = window
+	local need = used + n - limit
+	if n <= limit then
+		local entry = redis.call('ZRANGE', KEYS[1], need - 1, need - 1, 'WITHSCORES')
+		if #entry > 0 then
+			retry = tonumber(entry[2]) + window - now
+		end
+	end
+	return {0, limit - used, reset, retry}
+end
+
+if consume and n > 0 then
+	for i = 1, n do
+		redis.call('ZADD', KEYS[1], now, nonce .. ':' .. i)
+	end
+	redis.call('PEXPIRE', KEYS[1], window)
+	if used == 0 then
+		reset = window
+	end
+	used = used + n
+end
+return {1, limit - used, reset, 0}
+`)
+
+var bucketScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local consume = ARGV[3] == '1'
+local window = tonumber(ARGV[4])
+local now = tonumber(ARGV[5])
+local capacity = tonumber(ARGV[6])
+
+local rate = limit / window
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+if tokens < n then
+	local retry = window
+	if n <= capacity then
+		retry = math.ceil((n - tokens) / rate)
+	end
+	return {0, math.floor(tokens), math.ceil((capacity - tokens) / rate), retry}
+end
+
+if consume then
+	tokens = tokens - n
+	redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+	redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate) + 1000)
+end
+return {1, math.floor(tokens), math.ceil((capacity - tokens) / rate), 0}
+`)
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+func (r *Result) ResetAt() time.Time {
+	return time.Now().Add(r.Reset)
+}
+
+func Valid(algorithm string) bool {
+	switch algorithm {
+	case "", AlgorithmFixed, AlgorithmSliding, AlgorithmTokenBucket:
+		return true
+	}
+	return false
+}
+
+func Peek(ctx context.Context, apiKeyID uint, algorithm string, limit, burst, n int) (*Result, error) {
+	return run(ctx, apiKeyID, algorithm, limit, burst, n, false)
+}
+
+func Take(ctx context.Context, apiKeyID uint, algorithm string, limit, burst, n int) (*Result, error) {
+	return run(ctx, apiKeyID, algorithm, limit, burst, n, true)
+}
+
+func Reset(ctx context.Context, apiKeyID uint) error {
+	return queue.Client.Del(ctx,
+		key(apiKeyID, AlgorithmFixed),
+		key(apiKeyID, AlgorithmSliding),
+		key(apiKeyID, AlgorithmTokenBucket),
+	).Err()
+}
+
+func key(apiKeyID uint, algorithm string) string {
+	switch algorithm {
+	case AlgorithmSliding:
+		return fmt.Sprintf("mailflow:ratelimit:sliding:%d", apiKeyID)
+	case AlgorithmTokenBucket:
+		return fmt.Sprintf("mailflow:ratelimit:bucket:%d", apiKeyID)
+	default:
+		return fmt.Sprintf("mailflow:minute:%d", apiKeyID)
+	}
+}
+
+func run(ctx context.Context, apiKeyID uint, algorithm string, limit, burst, n int, consume bool) (*Result, error) {
+	if limit <= 0 {
+		return &Result{Allowed: true, Remaining: -1}, nil
+	}
+
+	flag := "0"
+	if consume {
+		flag = "1"
+	}
+	window := Window.Milliseconds()
+	now := time.Now().UnixMilli()
+	keys := []string{key(apiKeyID, algorithm)}
+
+	var (
+		values []interface{}
+		err    error
+	)
+	switch algorithm {
+	case AlgorithmSliding:
+		values, err = slidingScript.Run(ctx, queue.Client, keys, limit, n, flag, window, now, uuid.New().String()).Slice()
+	case AlgorithmTokenBucket:
+		capacity := burst
+		if capacity <= 0 {
+			capacity = limit
+		}
+		values, err = bucketScript.Run(ctx, queue.Client, keys, limit, n, flag, window, now, capacity).Slice()
+		limit = capacity
+	default:
+		values, err = fixedScript.Run(ctx, queue.Client, keys, limit, n, flag, window).Slice()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("速率限制检查失败: %w", err)
+	}
+
+	result := &Result{
+		Allowed:    values[0].(int64) == 1,
+		Limit:      limit,
+		Remaining:  int(max(values[1].(int64), 0)),
+		Reset:      time.Duration(values[2].(int64)) * time.Millisecond,
+		RetryAfter: time.Duration(values[3].(int64)) * time.Millisecond,
+	}
+	return result, nil
+}
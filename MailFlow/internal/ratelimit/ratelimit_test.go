@@ -0,0 +1,179 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+func setup(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	queue.Client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { queue.Client.Close() })
+	return mr
+}
+
+func runScript(t *testing.T, script *redis.Script, key string, args ...interface{}) []interface{} {
+	t.Helper()
+	values, err := script.Run(context.Background(), queue.Client, []string{key}, args...).Slice()
+	if err != nil {
+		t.Fatalf("script failed: %v", err)
+	}
+	return values
+}
+
+func take(t *testing.T, algorithm string, limit, burst, n int) *Result {
+	t.Helper()
+	result, err := Take(context.Background(), 1, algorithm, limit, burst, n)
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	return result
+}
+
+func TestValid(t *testing.T) {
+	for _, algorithm := range []string{"", AlgorithmFixed, AlgorithmSliding, AlgorithmTokenBucket} {
+		if !Valid(algorithm) {
+			t.Errorf("Valid(%q) = false", algorithm)
+		}
+	}
+	if Valid("leaky_bucket") {
+		t.Error("Valid(leaky_bucket) = true")
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	setup(t)
+	for _, algorithm := range []string{AlgorithmFixed, AlgorithmSliding, AlgorithmTokenBucket} {
+		if result := take(t, algorithm, 0, 0, 1000); !result.Allowed || result.Remaining != -1 {
+			t.Errorf("%s: unlimited key got %+v", algorithm, result)
+		}
+	}
+}
+
+func TestFixedWindow(t *testing.T) {
+	mr := setup(t)
+
+	if result := take(t, AlgorithmFixed, 3, 0, 2); !result.Allowed || result.Remaining != 1 {
+		t.Fatalf("first take: %+v", result)
+	}
+
+	peek, err := Peek(context.Background(), 1, AlgorithmFixed, 3, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peek.Allowed || peek.RetryAfter <= 0 {
+		t.Fatalf("peek over limit: %+v", peek)
+	}
+
+	if result := take(t, AlgorithmFixed, 3, 0, 1); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("peek must not consume: %+v", result)
+	}
+	if result := take(t, AlgorithmFixed, 3, 0, 1); result.Allowed {
+		t.Fatalf("take over limit: %+v", result)
+	}
+
+	mr.FastForward(Window + time.Second)
+	if result := take(t, AlgorithmFixed, 3, 0, 3); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("take after window: %+v", result)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	setup(t)
+	window := Window.Milliseconds()
+	sliding := func(now int64, n int, nonce string) []interface{} {
+		return runScript(t, slidingScript, "sliding", 3, n, "1", window, now, nonce)
+	}
+
+	if values := sliding(0, 2, "a"); values[0].(int64) != 1 || values[1].(int64) != 1 {
+		t.Fatalf("t=0: %v", values)
+	}
+	if values := sliding(30000, 1, "b"); values[0].(int64) != 1 || values[1].(int64) != 0 {
+		t.Fatalf("t=30s: %v", values)
+	}
+
+	values := sliding(40000, 1, "c")
+	if values[0].(int64) != 0 {
+		t.Fatalf("t=40s should be denied: %v", values)
+	}
+	if retry := values[3].(int64); retry != 20000 {
+		t.Fatalf("retry after = %d, want 20000", retry)
+	}
+
+	if values := sliding(60001, 2, "d"); values[0].(int64) != 1 || values[1].(int64) != 0 {
+		t.Fatalf("entries older than the window must expire: %v", values)
+	}
+	if values := sliding(60002, 1, "e"); values[0].(int64) != 0 {
+		t.Fatalf("t=60.002s should be denied: %v", values)
+	}
+}
+
+func TestSlidingWindowTake(t *testing.T) {
+	setup(t)
+	if result := take(t, AlgorithmSliding, 2, 0, 2); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("first take: %+v", result)
+	}
+	if result := take(t, AlgorithmSliding, 2, 0, 1); result.Allowed || result.RetryAfter <= 0 {
+		t.Fatalf("take over limit: %+v", result)
+	}
+	if result := take(t, AlgorithmSliding, 2, 0, 5); result.Allowed || result.RetryAfter != Window {
+		t.Fatalf("request larger than limit: %+v", result)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	setup(t)
+	window := Window.Milliseconds()
+	bucket := func(now int64, n int) []interface{} {
+		return runScript(t, bucketScript, "bucket", 60, n, "1", window, now, 5)
+	}
+
+	if values := bucket(0, 5); values[0].(int64) != 1 || values[1].(int64) != 0 {
+		t.Fatalf("burst: %v", values)
+	}
+
+	values := bucket(0, 1)
+	if values[0].(int64) != 0 {
+		t.Fatalf("empty bucket should deny: %v", values)
+	}
+	if retry := values[3].(int64); retry != 1000 {
+		t.Fatalf("retry after = %d, want 1000", retry)
+	}
+
+	if values := bucket(2000, 2); values[0].(int64) != 1 || values[1].(int64) != 0 {
+		t.Fatalf("refill after 2s: %v", values)
+	}
+	if values := bucket(600000, 6); values[0].(int64) != 0 || values[3].(int64) != window {
+		t.Fatalf("request above capacity: %v", values)
+	}
+	if values := bucket(600000, 5); values[0].(int64) != 1 {
+		t.Fatalf("refill is capped at capacity: %v", values)
+	}
+}
+
+func TestTokenBucketTake(t *testing.T) {
+	setup(t)
+	if result := take(t, AlgorithmTokenBucket, 60, 0, 60); !result.Allowed || result.Limit != 60 {
+		t.Fatalf("capacity defaults to limit: %+v", result)
+	}
+	if result := take(t, AlgorithmTokenBucket, 60, 0, 2); result.Allowed {
+		t.Fatalf("take over capacity: %+v", result)
+	}
+}
+
+func TestReset(t *testing.T) {
+	setup(t)
+	take(t, AlgorithmFixed, 1, 0, 1)
+	if err := Reset(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if result := take(t, AlgorithmFixed, 1, 0, 1); !result.Allowed {
+		t.Fatalf("take after reset: %+v", result)
+	}
+}
@@ -0,0 +1,90 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+type Result struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+func Validate(tpl *models.Template) error {
+	if _, err := texttemplate.New("subject").Parse(tpl.Subject); err != nil {
+		return fmt.Errorf("主题模板语法错误: %w", err)
+	}
+	if _, err := htmltemplate.New("html").Parse(tpl.HTML); err != nil {
+		return fmt.Errorf("HTML模板语法错误: %w", err)
+	}
+	if _, err := texttemplate.New("text").Parse(tpl.Text); err != nil {
+		return fmt.Errorf("文本模板语法错误: %w", err)
+	}
+	return nil
+}
+
+func Render(tpl *models.Template, vars map[string]interface{}) (*Result, error) {
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+
+	subject, err := renderText("subject", tpl.Subject, vars)
+	if err != nil {
+		return nil, fmt.Errorf("渲染主题失败: %w", err)
+	}
+	if strings.ContainsAny(subject, "\r\n") {
+		return nil, fmt.Errorf("渲染后的主题不能包含换行")
+	}
+
+	html, err := renderHTML(tpl.HTML, vars)
+	if err != nil {
+		return nil, fmt.Errorf("渲染HTML失败: %w", err)
+	}
+
+	text, err := renderText("text", tpl.Text, vars)
+	if err != nil {
+		return nil, fmt.Errorf("渲染文本失败: %w", err)
+	}
+
+	return &Result{Subject: subject, HTML: html, Text: text}, nil
+}
+
+func renderText(name, body string, vars map[string]interface{}) (string, error) {
+	if body == "" {
+		return "", nil
+	}
+
+	t, err := texttemplate.New(name).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func renderHTML(body string, vars map[string]interface{}) (string, error) {
+	if body == "" {
+		return "", nil
+	}
+
+	t, err := htmltemplate.New("html").Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
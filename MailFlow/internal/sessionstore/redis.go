@@ -0,0 +1,95 @@
+package sessionstore
+
+import (
+	"encoding/base32"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "mailflow:session:"
+
+var idEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type RedisStore struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+}
+
+func (s *RedisStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *RedisStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...); err != nil {
+		return session, err
+	}
+
+	data, err := queue.Client.Get(r.Context(), keyPrefix+session.ID).Result()
+	if err == redis.Nil {
+		session.ID = ""
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err := securecookie.DecodeMulti(name, data, &session.Values, s.Codecs...); err != nil {
+		return session, err
+	}
+
+	session.IsNew = false
+	return session, nil
+}
+
+func (s *RedisStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := queue.Client.Del(r.Context(), keyPrefix+session.ID).Err(); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = idEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+	}
+
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	ttl := time.Duration(session.Options.MaxAge) * time.Second
+	if err := queue.Client.Set(r.Context(), keyPrefix+session.ID, data, ttl).Err(); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+func Regenerate(r *http.Request, session *sessions.Session) {
+	if _, ok := session.Store().(*RedisStore); !ok || session.ID == "" {
+		return
+	}
+	queue.Client.Del(r.Context(), keyPrefix+session.ID)
+	session.ID = ""
+}
@@ -0,0 +1,115 @@
+package sessionstore
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/sessions"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	queue.Client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { queue.Client.Close() })
+	return mr
+}
+
+func TestRedisStoreRoundTrip(t *testing.T) {
+	mr := setupRedis(t)
+	store := newStore(t, "redis", oldSecret)
+	if _, ok := store.(*RedisStore); !ok {
+		t.Fatalf("NewStore(redis) = %T", store)
+	}
+
+	cookie := save(t, store, map[interface{}]interface{}{"admin_id": uint(3), "role": "owner"})
+	keys := mr.Keys()
+	if len(keys) != 1 || keys[0][:len(keyPrefix)] != keyPrefix {
+		t.Fatalf("redis keys = %v", keys)
+	}
+	if ttl := mr.TTL(keys[0]); ttl != time.Hour {
+		t.Fatalf("session TTL = %s, want 1h", ttl)
+	}
+	if len(cookie.Value) > 200 {
+		t.Fatal("redis store cookie must only carry the session id")
+	}
+
+	session, err := load(store, cookie)
+	if err != nil || session.IsNew || session.Values["admin_id"] != uint(3) || session.Values["role"] != "owner" {
+		t.Fatalf("load = %+v, %v", session, err)
+	}
+
+	mr.FastForward(time.Hour + time.Second)
+	session, err = load(store, cookie)
+	if err != nil || !session.IsNew || session.ID != "" || len(session.Values) != 0 {
+		t.Fatalf("expired server-side session must start fresh: %+v, %v", session, err)
+	}
+}
+
+func TestRedisStoreDelete(t *testing.T) {
+	mr := setupRedis(t)
+	store := newStore(t, "redis", oldSecret)
+	cookie := save(t, store, map[interface{}]interface{}{"admin_id": uint(3)})
+
+	session, _ := load(store, cookie)
+	session.Options.MaxAge = -1
+	rec := httptest.NewRecorder()
+	if err := store.Save(httptest.NewRequest("GET", "/admin/logout", nil), rec, session); err != nil {
+		t.Fatal(err)
+	}
+	if len(mr.Keys()) != 0 {
+		t.Fatal("logout must delete the server-side session")
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Fatalf("logout must expire the cookie: %+v", cookies)
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	mr := setupRedis(t)
+	store := newStore(t, "redis", oldSecret)
+	cookie := save(t, store, map[interface{}]interface{}{"admin_id": uint(3)})
+
+	req := httptest.NewRequest("POST", "/admin/login", nil)
+	req.AddCookie(cookie)
+	session, err := store.New(req, "mailflow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID := session.ID
+
+	Regenerate(req, session)
+	if session.ID != "" || mr.Exists(keyPrefix+oldID) {
+		t.Fatal("Regenerate must drop the old session id and its data")
+	}
+
+	rec := httptest.NewRecorder()
+	if err := store.Save(req, rec, session); err != nil {
+		t.Fatal(err)
+	}
+	if session.ID == "" || session.ID == oldID {
+		t.Fatal("saving after Regenerate must issue a new session id")
+	}
+	if reloaded, err := load(store, rec.Result().Cookies()[0]); err != nil || reloaded.Values["admin_id"] != uint(3) {
+		t.Fatalf("values must survive regeneration: %+v, %v", reloaded, err)
+	}
+	if session, _ := load(store, cookie); !session.IsNew {
+		t.Fatal("the pre-login cookie must no longer resolve to a session")
+	}
+}
+
+func TestRegenerateCookieStore(t *testing.T) {
+	store := newStore(t, "cookie", oldSecret)
+	req := httptest.NewRequest("POST", "/admin/login", nil)
+	session := sessions.NewSession(store, "mailflow")
+	session.ID = "unchanged"
+
+	Regenerate(req, session)
+	if session.ID != "unchanged" {
+		t.Fatal("Regenerate must be a no-op for the cookie store")
+	}
+}
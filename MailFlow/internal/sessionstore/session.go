@@ -0,0 +1,82 @@
+package sessionstore
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/mailflow/smtp-loadbalancer/internal/config"
+)
+
+func NewStore(cfg *config.SessionConfig) (sessions.Store, error) {
+	secrets := cfg.Secrets
+	if len(secrets) == 0 {
+		secret, err := randomSecret()
+		if err != nil {
+			return nil, err
+		}
+		secrets = []string{secret}
+		log.Println("警告: 未配置session.secrets，已使用临时随机密钥，重启后所有登录会话将失效")
+	}
+
+	codecs := securecookie.CodecsFromPairs(keyPairs(secrets)...)
+	maxAge := int(cfg.MaxAge.Seconds())
+	for _, codec := range codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(maxAge)
+		}
+	}
+
+	options := &sessions.Options{
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   cfg.Secure,
+		SameSite: sameSiteMode(cfg.SameSite),
+	}
+
+	if cfg.Store == "redis" {
+		return &RedisStore{Codecs: codecs, Options: options}, nil
+	}
+	return &sessions.CookieStore{Codecs: codecs, Options: options}, nil
+}
+
+func keyPairs(secrets []string) [][]byte {
+	pairs := make([][]byte, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		pairs = append(pairs, deriveKey(secret, "mailflow-session-auth"), deriveKey(secret, "mailflow-session-encrypt"))
+	}
+	return pairs
+}
+
+func deriveKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func randomSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成会话密钥失败: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func sameSiteMode(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
@@ -0,0 +1,127 @@
+package sessionstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/mailflow/smtp-loadbalancer/internal/config"
+)
+
+const (
+	oldSecret = "0123456789abcdef0123456789abcdef"
+	newSecret = "fedcba9876543210fedcba9876543210"
+)
+
+func newStore(t *testing.T, storeType string, secrets ...string) sessions.Store {
+	t.Helper()
+	store, err := NewStore(&config.SessionConfig{Secrets: secrets, Store: storeType, SameSite: "lax", MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func save(t *testing.T, store sessions.Store, values map[interface{}]interface{}) *http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/admin", nil)
+	session, _ := store.New(req, "mailflow")
+	for k, v := range values {
+		session.Values[k] = v
+	}
+	rec := httptest.NewRecorder()
+	if err := store.Save(req, rec, session); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Save set %d cookies", len(cookies))
+	}
+	return cookies[0]
+}
+
+func load(store sessions.Store, cookie *http.Cookie) (*sessions.Session, error) {
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.AddCookie(cookie)
+	return store.New(req, "mailflow")
+}
+
+func TestCookieStoreRoundTrip(t *testing.T) {
+	store := newStore(t, "cookie", oldSecret)
+	cookie := save(t, store, map[interface{}]interface{}{"admin_id": uint(1)})
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.MaxAge != 3600 {
+		t.Fatalf("unexpected cookie attributes: %+v", cookie)
+	}
+
+	session, err := load(store, cookie)
+	if err != nil || session.IsNew || session.Values["admin_id"] != uint(1) {
+		t.Fatalf("load = %+v, %v", session, err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	for _, storeType := range []string{"cookie", "redis"} {
+		if storeType == "redis" {
+			setupRedis(t)
+		}
+		cookie := save(t, newStore(t, storeType, oldSecret), map[interface{}]interface{}{"admin_id": uint(7)})
+
+		rotated := newStore(t, storeType, newSecret, oldSecret)
+		session, err := load(rotated, cookie)
+		if err != nil || session.Values["admin_id"] != uint(7) {
+			t.Fatalf("%s: cookie signed with the previous secret must still decode: %+v, %v", storeType, session.Values, err)
+		}
+
+		session, err = load(newStore(t, storeType, newSecret), cookie)
+		if err == nil && session.Values["admin_id"] != nil {
+			t.Fatalf("%s: cookie signed with a removed secret must be rejected", storeType)
+		}
+
+		fresh := save(t, rotated, map[interface{}]interface{}{"admin_id": uint(8)})
+		if session, err := load(newStore(t, storeType, newSecret), fresh); err != nil || session.Values["admin_id"] != uint(8) {
+			t.Fatalf("%s: new cookies must be signed with the first secret: %v", storeType, err)
+		}
+	}
+}
+
+func TestTamperedCookie(t *testing.T) {
+	store := newStore(t, "cookie", oldSecret)
+	cookie := save(t, store, map[interface{}]interface{}{"admin_id": uint(1)})
+	mid := len(cookie.Value) / 2
+	flipped := byte('A')
+	if cookie.Value[mid] == 'A' {
+		flipped = 'B'
+	}
+	cookie.Value = cookie.Value[:mid] + string(flipped) + cookie.Value[mid+1:]
+
+	if session, err := load(store, cookie); err == nil && session.Values["admin_id"] != nil {
+		t.Fatal("tampered cookie must not decode")
+	}
+}
+
+func TestNewStoreWithoutSecrets(t *testing.T) {
+	store := newStore(t, "cookie")
+	cookie := save(t, store, map[interface{}]interface{}{"admin_id": uint(1)})
+	if session, err := load(store, cookie); err != nil || session.Values["admin_id"] != uint(1) {
+		t.Fatalf("random secret store must round-trip: %v", err)
+	}
+	if _, err := load(newStore(t, "cookie"), cookie); err == nil {
+		t.Fatal("each random secret must be different")
+	}
+}
+
+func TestSameSiteMode(t *testing.T) {
+	tests := map[string]http.SameSite{
+		"strict": http.SameSiteStrictMode,
+		"None":   http.SameSiteNoneMode,
+		"lax":    http.SameSiteLaxMode,
+		"":       http.SameSiteLaxMode,
+	}
+	for value, want := range tests {
+		if got := sameSiteMode(value); got != want {
+			t.Errorf("sameSiteMode(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
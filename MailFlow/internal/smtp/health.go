@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/events"
 	"github.com/mailflow/smtp-loadbalancer/internal/models"
 	"gopkg.in/gomail.v2"
 )
@@ -62,6 +63,7 @@ func checkAllSMTP(ctx context.Context) {
 					config.LastCheckedAt = &now
 					database.DB.Save(&config)
 					log.Printf("SMTP[%s]自动恢复成功", config.Name)
+					publishHealthEvent(ctx, events.SMTPRecovered, &config)
 				} else {
 					recoverAt := now.Add(RecoveryDelay)
 					config.AutoRecoverAt = &recoverAt
@@ -193,6 +195,19 @@ func AutoDisableSMTP(smtpID uint) {
 	database.DB.Save(&config)
 	log.Printf("SMTP[%s]已自动禁用，连续失败%d次，将在%s尝试恢复", 
 		config.Name, config.FailureCount, recoverAt.Format("2006-01-02 15:04:05"))
+	publishHealthEvent(context.Background(), events.SMTPDisabled, &config)
+}
+
+func publishHealthEvent(ctx context.Context, eventType string, config *models.SMTPConfig) {
+	err := events.Publish(ctx, eventType, 0, map[string]interface{}{
+		"smtp_id":         config.ID,
+		"name":            config.Name,
+		"failure_count":   config.FailureCount,
+		"auto_recover_at": config.AutoRecoverAt,
+	})
+	if err != nil {
+		log.Printf("SMTP[%s]健康事件发布失败: %v", config.Name, err)
+	}
 }
 
 func AutoEnableSMTP(ctx context.Context, smtpID uint) error {
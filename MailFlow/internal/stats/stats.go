@@ -10,6 +10,7 @@ import (
 	"github.com/mailflow/smtp-loadbalancer/internal/database"
 	"github.com/mailflow/smtp-loadbalancer/internal/models"
 	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/mailflow/smtp-loadbalancer/internal/ratelimit"
 )
 
 func IncrementSent(ctx context.Context, apiKeyID uint) error {
@@ -273,13 +274,13 @@ func getMonthStart(t time.Time) time.Time {
 	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
 }
 
-func getAPIKeyUsage(ctx context.Context, apiKeyID uint) map[string]int64 {
+func getAPIKeyUsage(ctx context.Context, key *models.APIKey) map[string]int64 {
+	apiKeyID := key.ID
 	now := time.Now()
 	usage := make(map[string]int64)
 	
-	minuteKey := fmt.Sprintf("mailflow:minute:%d", apiKeyID)
-	if count, err := queue.Client.Get(ctx, minuteKey).Int64(); err == nil {
-		usage["minute"] = count
+	if limit, err := ratelimit.Peek(ctx, apiKeyID, key.RateLimitAlgorithm, key.MinuteLimit, key.BurstLimit, 0); err == nil && limit.Limit > 0 {
+		usage["minute"] = int64(limit.Limit - limit.Remaining)
 	}
 	
 	dailyKey := fmt.Sprintf("mailflow:daily:%d:%s", apiKeyID, now.Format("2006-01-02"))
@@ -416,7 +417,7 @@ func GetAPIKeyDetailStats() ([]APIKeyDetailStats, error) {
 	result := make([]APIKeyDetailStats, 0, len(keys))
 	
 	for _, key := range keys {
-		usage := getAPIKeyUsage(ctx, key.ID)
+		usage := getAPIKeyUsage(ctx, &key)
 		
 		todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 		todayEnd := todayStart.AddDate(0, 0, 1)
@@ -0,0 +1,193 @@
+package suppression
+
+import (
+	"fmt"
+	"log"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	Global = 0
+
+	TypeAddress = "address"
+	TypeDomain  = "domain"
+
+	ReasonBounce      = "bounce"
+	ReasonRejected    = "rejected"
+	ReasonComplaint   = "complaint"
+	ReasonManual      = "manual"
+	ReasonUnsubscribe = "unsubscribe"
+
+	SourceSMTP        = "smtp"
+	SourceDSN         = "dsn"
+	SourceFBL         = "feedback_loop"
+	SourceAdmin       = "admin"
+	SourceAPI         = "api"
+	SourceImport      = "import"
+	SourceUnsubscribe = "list_unsubscribe"
+
+	MaxImportSize = 10000
+)
+
+var Reasons = []string{ReasonBounce, ReasonRejected, ReasonComplaint, ReasonManual, ReasonUnsubscribe}
+
+var (
+	enhancedStatusPattern = regexp.MustCompile(`\b([245])\.(\d{1,3})\.(\d{1,3})\b`)
+	replyCodePattern      = regexp.MustCompile(`(?:^|[\s:])(5\d\d)[\s-]`)
+	recipientPattern      = regexp.MustCompile(`(?i)(no such|unknown|invalid) (user|recipient|mailbox)|(user|recipient|mailbox) (unknown|not found|does not exist|doesn't exist|disabled)`)
+	domainLabelPattern    = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+)
+
+func Normalize(value string) (string, string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return "", "", fmt.Errorf("地址不能为空")
+	}
+
+	if strings.HasPrefix(value, "@") {
+		value = value[1:]
+	} else if strings.Contains(value, "@") {
+		addr, err := mail.ParseAddress(value)
+		if err != nil {
+			return "", "", fmt.Errorf("无效的邮箱地址: %s", value)
+		}
+		return strings.ToLower(addr.Address), TypeAddress, nil
+	}
+
+	if !validDomain(value) {
+		return "", "", fmt.Errorf("无效的域名: %s", value)
+	}
+	return value, TypeDomain, nil
+}
+
+func ValidReason(reason string) bool {
+	for _, r := range Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func Find(apiKeyID uint, email string, marketing bool) (*models.Suppression, error) {
+	address := strings.ToLower(strings.TrimSpace(email))
+	domain := ""
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		domain = address[at+1:]
+	}
+
+	var entry models.Suppression
+	query := database.DB.
+		Where("api_key_id IN ?", []uint{Global, apiKeyID}).
+		Where("(type = ? AND address = ?) OR (type = ? AND address = ?)", TypeAddress, address, TypeDomain, domain).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	if !marketing {
+		query = query.Where("reason <> ?", ReasonUnsubscribe)
+	}
+	result := query.Limit(1).Find(&entry)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func Save(entries []models.Suppression) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "api_key_id"}, {Name: "address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"type", "reason", "source", "detail", "expires_at", "updated_at"}),
+	}).CreateInBatches(&entries, 500).Error
+}
+
+func RecordBounce(email, source, detail string) bool {
+	if !IsHardBounce(detail) {
+		return false
+	}
+	return record(Global, email, ReasonBounce, source, detail, nil)
+}
+
+func RecordComplaint(apiKeyID uint, email, source, detail string) {
+	record(apiKeyID, email, ReasonComplaint, source, detail, nil)
+}
+
+func RecordUnsubscribe(apiKeyID uint, email, detail string) bool {
+	return record(apiKeyID, email, ReasonUnsubscribe, SourceUnsubscribe, detail, nil)
+}
+
+func IsHardBounce(text string) bool {
+	if match := enhancedStatusPattern.FindStringSubmatch(text); match != nil {
+		if match[1] != "5" {
+			return false
+		}
+		switch match[2] {
+		case "1":
+			return match[3] != "7" && match[3] != "8"
+		case "2":
+			return match[3] == "1"
+		}
+		return false
+	}
+	if match := replyCodePattern.FindStringSubmatch(text); match != nil {
+		switch match[1] {
+		case "550", "551", "553":
+			return recipientPattern.MatchString(text)
+		}
+	}
+	return false
+}
+
+func record(apiKeyID uint, email, reason, source, detail string, expiresAt *time.Time) bool {
+	address, kind, err := Normalize(email)
+	if err != nil || kind != TypeAddress {
+		return false
+	}
+
+	var existing models.Suppression
+	if err := database.DB.Where("api_key_id = ? AND address = ?", apiKeyID, address).First(&existing).Error; err == nil {
+		permanent := existing.ExpiresAt == nil
+		active := permanent || existing.ExpiresAt.After(time.Now())
+		upgrade := expiresAt == nil && (!permanent || (existing.Reason == ReasonUnsubscribe && reason != ReasonUnsubscribe))
+		if active && !upgrade {
+			return false
+		}
+	}
+
+	entry := models.Suppression{
+		APIKeyID:  apiKeyID,
+		Address:   address,
+		Type:      TypeAddress,
+		Reason:    reason,
+		Source:    source,
+		Detail:    detail,
+		ExpiresAt: expiresAt,
+	}
+	if err := Save([]models.Suppression{entry}); err != nil {
+		log.Printf("写入抑制列表失败 [%s]: %v", address, err)
+		return false
+	}
+	return true
+}
+
+func validDomain(domain string) bool {
+	if len(domain) > 253 || !strings.Contains(domain, ".") {
+		return false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if len(label) > 63 || !domainLabelPattern.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
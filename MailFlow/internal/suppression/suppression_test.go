@@ -0,0 +1,63 @@
+package suppression
+
+import "testing"
+
+func TestIsHardBounce(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"SMTP发送失败: 550 5.1.1 <user@example.org>: Recipient address rejected: User unknown", true},
+		{"SMTP发送失败: 550-5.1.1 The email account that you tried to reach does not exist", true},
+		{"SMTP发送失败: 553 5.1.3 Bad recipient address syntax", true},
+		{"SMTP发送失败: 550 5.2.1 Mailbox disabled", true},
+		{"退信 5.1.1: 550 5.1.1 <user@example.org>: Recipient address rejected", true},
+		{"SMTP发送失败: 550 No such user here", true},
+		{"SMTP发送失败: 550 Recipient not found", true},
+		{"SMTP发送失败: 550 5.1.7 Invalid sender address", false},
+		{"SMTP发送失败: 553 5.1.8 Sender domain does not exist", false},
+		{"SMTP发送失败: 552 5.2.2 Mailbox full", false},
+		{"SMTP发送失败: 554 5.7.1 Relay access denied", false},
+		{"SMTP发送失败: 552 5.3.4 Message size exceeds fixed limit", false},
+		{"SMTP发送失败: 550 5.7.1 Unauthenticated email is not accepted due to DMARC policy", false},
+		{"退信 5.7.1: 550 5.7.1 Message rejected as spam", false},
+		{"SMTP发送失败: 550 sender not allowed", false},
+		{"SMTP发送失败: 554 Relay denied", false},
+		{"SMTP发送失败: 450 4.2.1 Mailbox temporarily unavailable", false},
+		{"SMTP发送失败: 421 Service not available", false},
+		{"SMTP发送失败: EOF", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsHardBounce(tt.text); got != tt.want {
+			t.Errorf("IsHardBounce(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		value   string
+		address string
+		kind    string
+		wantErr bool
+	}{
+		{" User@Example.COM ", "user@example.com", TypeAddress, false},
+		{"Joe <joe@example.com>", "joe@example.com", TypeAddress, false},
+		{"@Example.com", "example.com", TypeDomain, false},
+		{"example.com", "example.com", TypeDomain, false},
+		{"", "", "", true},
+		{"not an address@", "", "", true},
+	}
+
+	for _, tt := range tests {
+		address, kind, err := Normalize(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Normalize(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if address != tt.address || kind != tt.kind {
+			t.Errorf("Normalize(%q) = (%q, %q), want (%q, %q)", tt.value, address, kind, tt.address, tt.kind)
+		}
+	}
+}
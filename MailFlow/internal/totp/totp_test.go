@@ -0,0 +1,136 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+
+	if code, err := Code(strings.ToLower(rfcSecret), 1); err != nil || code != "287082" {
+		t.Errorf("lowercase secret: %s, %v", code, err)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("invalid secret should fail")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+	codeAt := func(step int64) string {
+		code, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		want     bool
+		wantStep int64
+	}{
+		{"current", codeAt(current), 0, true, current},
+		{"previous step within skew", codeAt(current - 1), 0, true, current - 1},
+		{"next step within skew", codeAt(current + 1), 0, true, current + 1},
+		{"outside skew", codeAt(current - 2), 0, false, 0},
+		{"replayed step", codeAt(current), current, false, 0},
+		{"older than last used step", codeAt(current - 1), current - 1, false, 0},
+		{"later step after use", codeAt(current + 1), current, true, current + 1},
+		{"spaces are ignored", codeAt(current)[:3] + " " + codeAt(current)[3:], 0, true, current},
+		{"wrong length", "12345", 0, false, 0},
+		{"wrong code", "000000", 0, false, 0},
+	}
+
+	for _, tt := range tests {
+		step, ok := Validate(rfcSecret, tt.code, now, tt.lastStep)
+		if ok != tt.want || step != tt.wantStep {
+			t.Errorf("%s: Validate = (%d, %v), want (%d, %v)", tt.name, step, ok, tt.wantStep, tt.want)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Fatalf("secret length = %d, want 32", len(secret))
+	}
+	if _, err := Code(secret, 1); err != nil {
+		t.Fatalf("generated secret is not usable: %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes", len(codes))
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Fatalf("unexpected code format: %q", code)
+		}
+		hashes[i] = HashRecoveryCode(code)
+	}
+	stored := strings.Join(hashes, ",")
+
+	remaining, ok := UseRecoveryCode(stored, strings.ToUpper(strings.ReplaceAll(codes[3], "-", "")))
+	if !ok {
+		t.Fatal("recovery code should be accepted regardless of case and dash")
+	}
+	if strings.Contains(remaining, hashes[3]) || len(strings.Split(remaining, ",")) != RecoveryCodeCount-1 {
+		t.Fatal("used recovery code must be removed")
+	}
+	if stored != strings.Join(hashes, ",") {
+		t.Fatal("UseRecoveryCode must not modify its input")
+	}
+
+	if _, ok := UseRecoveryCode(remaining, codes[3]); ok {
+		t.Fatal("recovery code must not be reusable")
+	}
+	if _, ok := UseRecoveryCode("", "abcde-12345"); ok {
+		t.Fatal("empty list must reject every code")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("MailFlow", "admin", rfcSecret)
+	for _, part := range []string{"otpauth://totp/MailFlow:admin?", "secret=" + rfcSecret, "issuer=MailFlow", "digits=6", "period=30"} {
+		if !strings.Contains(uri, part) {
+			t.Errorf("ProvisioningURI missing %q: %s", part, uri)
+		}
+	}
+}
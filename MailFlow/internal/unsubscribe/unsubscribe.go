@@ -0,0 +1,116 @@
+package unsubscribe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/config"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/events"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"github.com/mailflow/smtp-loadbalancer/internal/suppression"
+)
+
+const (
+	Path         = "/unsubscribe/"
+	OneClickBody = "List-Unsubscribe=One-Click"
+)
+
+var ErrInvalidToken = errors.New("退订链接无效")
+
+var (
+	baseURL string
+	secret  []byte
+)
+
+type Claims struct {
+	APIKeyID    uint
+	RecipientID string
+	Email       string
+}
+
+func Configure(cfg *config.UnsubscribeConfig) {
+	baseURL = cfg.BaseURL
+	secret = []byte(cfg.Secret)
+	if !Enabled() {
+		log.Println("警告: 未配置unsubscribe.base_url或unsubscribe.secret，无法发送营销邮件")
+	}
+}
+
+func Enabled() bool {
+	return baseURL != "" && len(secret) > 0
+}
+
+func URL(apiKeyID uint, recipientID, email string) string {
+	if !Enabled() {
+		return ""
+	}
+	return baseURL + Path + Token(Claims{APIKeyID: apiKeyID, RecipientID: recipientID, Email: email})
+}
+
+func Token(claims Claims) string {
+	payload := fmt.Sprintf("%d|%s|%s", claims.APIKeyID, claims.RecipientID, strings.ToLower(claims.Email))
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + sign(encoded)
+}
+
+func Parse(token string) (*Claims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !Enabled() || !hmac.Equal([]byte(signature), []byte(sign(encoded))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	parts := strings.SplitN(string(payload), "|", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return nil, ErrInvalidToken
+	}
+	apiKeyID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &Claims{APIKeyID: uint(apiKeyID), RecipientID: parts[1], Email: parts[2]}, nil
+}
+
+func Record(ctx context.Context, claims *Claims) error {
+	var key models.APIKey
+	if err := database.DB.Select("id").First(&key, claims.APIKeyID).Error; err != nil {
+		return ErrInvalidToken
+	}
+
+	if !suppression.RecordUnsubscribe(claims.APIKeyID, claims.Email, claims.RecipientID) {
+		return nil
+	}
+
+	data := map[string]interface{}{
+		"recipient_id": claims.RecipientID,
+		"to":           claims.Email,
+	}
+	var sendLog models.SendLog
+	if claims.RecipientID != "" && database.DB.Where("recipient_id = ?", claims.RecipientID).First(&sendLog).Error == nil {
+		data["message_id"] = sendLog.MessageID
+		data["subject"] = sendLog.Subject
+	}
+	if err := events.Publish(ctx, events.EmailUnsubscribed, claims.APIKeyID, data); err != nil {
+		log.Printf("发布事件失败 [%s] [%s]: %v", events.EmailUnsubscribed, claims.Email, err)
+	}
+	log.Printf("收件人已退订 [API Key: %d] [%s]", claims.APIKeyID, claims.Email)
+	return nil
+}
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,97 @@
+package unsubscribe
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/config"
+)
+
+func configure(t *testing.T, base, key string) {
+	t.Helper()
+	Configure(&config.UnsubscribeConfig{BaseURL: base, Secret: key})
+	t.Cleanup(func() { baseURL, secret = "", nil })
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	configure(t, "https://mail.example.com", "test-secret")
+
+	claims := Claims{APIKeyID: 42, RecipientID: "3f2b8c1e-9d4a-4f6b-8e2c-1a7d5b9c0e44", Email: "User@Example.com"}
+	token := Token(claims)
+	if strings.ContainsAny(token, "+/=") {
+		t.Fatalf("token is not URL safe: %s", token)
+	}
+
+	parsed, err := Parse(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.APIKeyID != 42 || parsed.RecipientID != claims.RecipientID || parsed.Email != "user@example.com" {
+		t.Fatalf("Parse = %+v", parsed)
+	}
+
+	parsed, err = Parse(Token(Claims{APIKeyID: 7, Email: "a|b@example.com"}))
+	if err != nil || parsed.RecipientID != "" || parsed.Email != "a|b@example.com" {
+		t.Fatalf("Parse without recipient id = %+v, %v", parsed, err)
+	}
+}
+
+func TestURL(t *testing.T) {
+	if got := URL(1, "r", "user@example.com"); got != "" {
+		t.Fatalf("URL while disabled = %q", got)
+	}
+
+	configure(t, "https://mail.example.com", "test-secret")
+	got := URL(1, "r", "user@example.com")
+	prefix := "https://mail.example.com" + Path
+	if !strings.HasPrefix(got, prefix) {
+		t.Fatalf("URL = %q", got)
+	}
+	if claims, err := Parse(strings.TrimPrefix(got, prefix)); err != nil || claims.Email != "user@example.com" {
+		t.Fatalf("URL token = %+v, %v", claims, err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	configure(t, "https://mail.example.com", "test-secret")
+	token := Token(Claims{APIKeyID: 42, RecipientID: "r1", Email: "user@example.com"})
+	encoded, signature, _ := strings.Cut(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte("43|r1|user@example.com"))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"missing signature", encoded},
+		{"tampered payload", forged + "." + signature},
+		{"tampered signature", encoded + "." + strings.Repeat("A", len(signature))},
+		{"not base64", "!!!." + sign("!!!")},
+		{"missing fields", signed("42|user@example.com")},
+		{"empty email", signed("42|r1|")},
+		{"invalid api key id", signed("abc|r1|user@example.com")},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse(tt.token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: Parse error = %v, want ErrInvalidToken", tt.name, err)
+		}
+	}
+
+	configure(t, "https://mail.example.com", "other-secret")
+	if _, err := Parse(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatal("token signed with another secret must be rejected")
+	}
+
+	configure(t, "", "")
+	if _, err := Parse(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatal("tokens must be rejected while unsubscribe is disabled")
+	}
+}
+
+func signed(payload string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + sign(encoded)
+}
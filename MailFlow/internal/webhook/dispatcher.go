@@ -0,0 +1,330 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/events"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ConsumerGroup   = "webhooks"
+	MaxAttempts     = 8
+	RetryBaseDelay  = 30 * time.Second
+	RetryMaxDelay   = 2 * time.Hour
+	DeliveryTimeout = 10 * time.Second
+	PollInterval    = 5 * time.Second
+	StaleTimeout    = 5 * time.Minute
+	ClaimInterval   = time.Minute
+	Concurrency     = 10
+
+	batchSize       = 100
+	maxResponseSize = 1024
+)
+
+var errPrivateAddress = errors.New("禁止向内网地址投递Webhook")
+
+var allowPrivate bool
+
+var client = &http.Client{
+	Timeout: DeliveryTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: DeliveryTimeout,
+			Control: guardAddress,
+		}).DialContext,
+		TLSHandshakeTimeout: DeliveryTimeout,
+		MaxIdleConnsPerHost: 2,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func Start(ctx context.Context, allowPrivateNetworks bool) {
+	allowPrivate = allowPrivateNetworks
+
+	if err := events.EnsureGroup(ctx, ConsumerGroup); err != nil {
+		log.Printf("Webhook分发器启动失败: %v", err)
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	consumer := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+
+	go consume(ctx, consumer)
+	go reclaimLoop(ctx, consumer)
+	go deliverLoop(ctx)
+}
+
+func consume(ctx context.Context, consumer string) {
+	for ctx.Err() == nil {
+		list, err := events.ReadGroup(ctx, ConsumerGroup, consumer, ">", batchSize, PollInterval)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Webhook读取事件失败: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		handle(ctx, list)
+	}
+}
+
+func reclaimLoop(ctx context.Context, consumer string) {
+	ticker := time.NewTicker(ClaimInterval)
+	defer ticker.Stop()
+
+	for {
+		reclaim(ctx, consumer)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func reclaim(ctx context.Context, consumer string) {
+	start := "0-0"
+	for ctx.Err() == nil {
+		list, next, err := events.Claim(ctx, ConsumerGroup, consumer, StaleTimeout, start, batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Webhook认领待处理事件失败: %v", err)
+			}
+			return
+		}
+		handle(ctx, list)
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+func handle(ctx context.Context, list []events.Event) {
+	for _, event := range list {
+		if err := fanOut(&event); err != nil {
+			log.Printf("Webhook事件[%s]分发失败: %v", event.ID, err)
+			continue
+		}
+		if err := events.Ack(ctx, ConsumerGroup, event.ID); err != nil {
+			log.Printf("Webhook事件[%s]确认失败: %v", event.ID, err)
+		}
+	}
+}
+
+func fanOut(event *events.Event) error {
+	if !validEvent(event.Type) {
+		return nil
+	}
+
+	owner := event.APIKeyID
+	if systemEvent(event.Type) {
+		owner = 0
+	} else if owner == 0 {
+		return nil
+	}
+
+	var hooks []models.Webhook
+	if err := database.DB.Where("api_key_id = ? AND is_active = ?", owner, true).Find(&hooks).Error; err != nil {
+		return err
+	}
+
+	var payload []byte
+	now := time.Now()
+	for _, hook := range hooks {
+		if !Subscribed(&hook, event.Type) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(event); err != nil {
+				return err
+			}
+		}
+
+		delivery := models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			APIKeyID:      hook.APIKeyID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       string(payload),
+			Status:        "pending",
+			NextAttemptAt: &now,
+		}
+		if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deliverLoop(ctx context.Context) {
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			releaseStale()
+			deliverDue(ctx)
+		}
+	}
+}
+
+func releaseStale() {
+	database.DB.Model(&models.WebhookDelivery{}).
+		Where("status = ? AND updated_at < ?", "delivering", time.Now().Add(-StaleTimeout)).
+		Update("status", "pending")
+}
+
+func deliverDue(ctx context.Context) {
+	var due []models.WebhookDelivery
+	if err := database.DB.Where("status = ? AND next_attempt_at <= ?", "pending", time.Now()).
+		Order("next_attempt_at ASC").Limit(batchSize).Find(&due).Error; err != nil {
+		log.Printf("查询待投递Webhook失败: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, Concurrency)
+	for i := range due {
+		delivery := &due[i]
+		result := database.DB.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ?", delivery.ID, "pending").
+			Updates(map[string]interface{}{"status": "delivering", "updated_at": time.Now()})
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+func deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	attempt := delivery.Attempts + 1
+	updates := map[string]interface{}{"attempts": attempt}
+
+	var hook models.Webhook
+	err := database.DB.First(&hook, delivery.WebhookID).Error
+	if err != nil || !hook.IsActive {
+		updates["status"] = "failed"
+		updates["last_error"] = "Webhook已删除或已停用"
+		updates["next_attempt_at"] = nil
+		database.DB.Model(delivery).Updates(updates)
+		return
+	}
+
+	started := time.Now()
+	code, response, err := post(ctx, &hook, delivery)
+	record := models.WebhookAttempt{
+		DeliveryID: delivery.ID,
+		Attempt:    attempt,
+		StatusCode: code,
+		Response:   response,
+		DurationMS: time.Since(started).Milliseconds(),
+	}
+	if err == nil && (code < 200 || code >= 300) {
+		err = fmt.Errorf("接收端返回HTTP %d", code)
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	database.DB.Create(&record)
+
+	updates["last_status_code"] = code
+	updates["last_error"] = record.Error
+	switch {
+	case err == nil:
+		now := time.Now()
+		updates["status"] = "success"
+		updates["delivered_at"] = &now
+		updates["next_attempt_at"] = nil
+	case attempt >= MaxAttempts:
+		updates["status"] = "failed"
+		updates["next_attempt_at"] = nil
+		log.Printf("Webhook[%d]投递彻底失败 [事件: %s]: %v", hook.ID, delivery.EventID, err)
+	default:
+		next := time.Now().Add(retryDelay(attempt))
+		updates["status"] = "pending"
+		updates["next_attempt_at"] = &next
+	}
+	database.DB.Model(delivery).Updates(updates)
+}
+
+func post(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "MailFlow-Webhook/1.0")
+	req.Header.Set("X-MailFlow-Event", delivery.EventType)
+	req.Header.Set("X-MailFlow-Event-ID", delivery.EventID)
+	req.Header.Set("X-MailFlow-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, time.Now().Unix(), body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	return resp.StatusCode, string(data), nil
+}
+
+func retryDelay(attempt int) time.Duration {
+	delay := RetryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > RetryMaxDelay {
+		delay = RetryMaxDelay
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func guardAddress(network, address string, conn syscall.RawConn) error {
+	if allowPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errPrivateAddress
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/events"
+	"github.com/mailflow/smtp-loadbalancer/internal/models"
+)
+
+const (
+	SecretPrefix      = "whsec_"
+	SignatureHeader   = "X-MailFlow-Signature"
+	TestEvent         = "webhook.test"
+	MaxWebhooksPerKey = 10
+)
+
+var Events = []string{
+	events.EmailQueued,
+	events.EmailSent,
+	events.EmailFailed,
+	events.EmailBounced,
+	events.EmailComplained,
+	events.EmailSuppressed,
+	events.EmailUnsubscribed,
+}
+
+var SystemEvents = []string{
+	events.SMTPDisabled,
+	events.SMTPRecovered,
+}
+
+func NormalizeEvents(list []string, system bool) (string, error) {
+	allowed := eventsFor(system)
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(allowed))
+	for _, eventType := range list {
+		eventType = strings.ToLower(strings.TrimSpace(eventType))
+		if eventType == "" {
+			continue
+		}
+		if !contains(allowed, eventType) {
+			return "", fmt.Errorf("无效的事件类型: %s", eventType)
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			normalized = append(normalized, eventType)
+		}
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+func AdminOwned(hook *models.Webhook) bool {
+	return hook.APIKeyID == 0
+}
+
+func eventsFor(system bool) []string {
+	if system {
+		return SystemEvents
+	}
+	return Events
+}
+
+func validEvent(eventType string) bool {
+	return contains(Events, eventType) || systemEvent(eventType)
+}
+
+func systemEvent(eventType string) bool {
+	return contains(SystemEvents, eventType)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func Subscribed(hook *models.Webhook, eventType string) bool {
+	if !contains(eventsFor(AdminOwned(hook)), eventType) {
+		return false
+	}
+	return hook.Events == "" || contains(strings.Split(hook.Events, ","), eventType)
+}
+
+func ValidateURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("Webhook地址必须是有效的http或https URL")
+	}
+	if u.User != nil {
+		return fmt.Errorf("Webhook地址不能包含用户名或密码")
+	}
+	return nil
+}
+
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成Webhook密钥失败: %w", err)
+	}
+	return SecretPrefix + hex.EncodeToString(buf), nil
+}
+
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func SendTest(hook *models.Webhook) (*models.WebhookDelivery, error) {
+	event := events.Event{
+		ID:        "test-" + uuid.New().String(),
+		Type:      TestEvent,
+		APIKeyID:  hook.APIKeyID,
+		Data:      map[string]interface{}{"message": "This is a test event from MailFlow."},
+		CreatedAt: time.Now(),
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	delivery := models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		APIKeyID:      hook.APIKeyID,
+		EventID:       event.ID,
+		EventType:     event.Type,
+		Payload:       string(payload),
+		Status:        "pending",
+		NextAttemptAt: &now,
+	}
+	if err := database.DB.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
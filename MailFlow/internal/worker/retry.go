@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"time"
+
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/mailflow/smtp-loadbalancer/internal/suppression"
+)
+
+var smtpReplyPattern = regexp.MustCompile(`(?:^|[\s:])([2-5])(\d\d)[\s-]`)
+
+var errAttachmentUnavailable = errors.New("附件不可用")
+
+func isPermanentError(err error) bool {
+	if errors.Is(err, errAttachmentUnavailable) {
+		return true
+	}
+
+	match := smtpReplyPattern.FindStringSubmatch(err.Error())
+	if match == nil || match[1] != "5" {
+		return false
+	}
+
+	switch match[1] + match[2] {
+	case "530", "534", "535", "538":
+		return false
+	}
+	return true
+}
+
+func isBounce(err error) bool {
+	return isPermanentError(err) && !errors.Is(err, errAttachmentUnavailable) && suppression.IsHardBounce(err.Error())
+}
+
+func retryDelay(attempt int) time.Duration {
+	delay := policy.RetryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > policy.RetryMaxDelay {
+		delay = policy.RetryMaxDelay
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func scheduleRetry(ctx context.Context, task *queue.EmailTask, recipient queue.Recipient, attempt int, history []queue.AttemptError, delay time.Duration) error {
+	retry := *task
+	retry.Recipients = []queue.Recipient{recipient}
+	retry.Attempt = attempt
+	retry.Errors = history
+
+	id := fmt.Sprintf("%s:%s:%d", task.MessageID, recipient.ID, attempt)
+	return queue.ScheduleEmail(ctx, id, &retry, time.Now().Add(delay))
+}
@@ -0,0 +1,149 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mailflow/smtp-loadbalancer/internal/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestIsPermanentError(t *testing.T) {
+	tests := []struct {
+		err  string
+		want bool
+	}{
+		{"SMTP发送失败: 550 5.1.1 <user@example.com>: Recipient address rejected", true},
+		{"SMTP发送失败: 554 5.7.1 Relay access denied", true},
+		{"SMTP发送失败: 552-5.3.4 Message size exceeds fixed limit", true},
+		{"SMTP发送失败: 530 5.7.0 Must issue a STARTTLS command first", false},
+		{"SMTP发送失败: 535 5.7.8 Authentication credentials invalid", false},
+		{"SMTP发送失败: 421 4.7.0 Try again later", false},
+		{"SMTP发送失败: 451 4.3.0 Temporary lookup failure", false},
+		{"SMTP发送失败: dial tcp 10.0.0.1:587: i/o timeout", false},
+		{"SMTP发送失败: EOF", false},
+	}
+
+	for _, tt := range tests {
+		if got := isPermanentError(errors.New(tt.err)); got != tt.want {
+			t.Errorf("isPermanentError(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestIsPermanentErrorAttachment(t *testing.T) {
+	err := fmt.Errorf("%w: 附件已过期或不存在", errAttachmentUnavailable)
+	if !isPermanentError(err) {
+		t.Fatal("missing attachments must not be retried")
+	}
+}
+
+func TestIsBounce(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("SMTP发送失败: 550 5.1.1 <user@example.com>: Recipient address rejected: User unknown"), true},
+		{errors.New("SMTP发送失败: 550 5.2.1 Mailbox disabled"), true},
+		{errors.New("SMTP发送失败: 550 No such user"), true},
+		{errors.New("SMTP发送失败: 554 5.7.1 Relay access denied"), false},
+		{errors.New("SMTP发送失败: 552 5.3.4 Message size exceeds fixed limit"), false},
+		{errors.New("SMTP发送失败: 550 5.7.1 Rejected due to DMARC policy"), false},
+		{errors.New("SMTP发送失败: 550 sender not allowed"), false},
+		{errors.New("SMTP发送失败: 450 4.1.1 Recipient unknown, try again"), false},
+		{fmt.Errorf("%w: 550 5.1.1 附件已过期或不存在", errAttachmentUnavailable), false},
+	}
+
+	for _, tt := range tests {
+		if got := isBounce(tt.err); got != tt.want {
+			t.Errorf("isBounce(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestHandled(t *testing.T) {
+	tests := []struct {
+		status        string
+		loggedAttempt int
+		taskAttempt   int
+		want          bool
+	}{
+		{"queued", 0, 0, false},
+		{"sending", 1, 0, false},
+		{"retrying", 1, 0, true},
+		{"retrying", 1, 1, false},
+		{"retrying", 0, 0, false},
+		{"success", 1, 0, true},
+		{"bounced", 1, 1, true},
+		{"failed", 3, 2, true},
+		{"suppressed", 1, 0, true},
+		{"cancelled", 0, 0, true},
+		{"", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		if got := handled(tt.status, tt.loggedAttempt, tt.taskAttempt); got != tt.want {
+			t.Errorf("handled(%q, %d, %d) = %v, want %v", tt.status, tt.loggedAttempt, tt.taskAttempt, got, tt.want)
+		}
+	}
+}
+
+func TestCrashBetweenScheduleAndAck(t *testing.T) {
+	mr := miniredis.RunT(t)
+	queue.Client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { queue.Client.Close() })
+	ctx := context.Background()
+	const consumer = "host:1:0"
+
+	original := &queue.EmailTask{To: []string{"user@example.org"}, Subject: "Hello"}
+	original.AssignIDs()
+	if err := queue.PushEmail(ctx, original); err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := queue.PopEmail(ctx, consumer, time.Second)
+	if err != nil || task == nil {
+		t.Fatalf("PopEmail = %v, %v", task, err)
+	}
+	recipient := task.Recipients[0]
+	attempt := task.Attempt + 1
+	if err := scheduleRetry(ctx, task, recipient, attempt, nil, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	loggedStatus, loggedAttempt := "retrying", attempt
+
+	if err := queue.Client.RPopLPush(ctx, queue.ProcessingKeyPrefix+consumer, queue.QueueKey).Err(); err != nil {
+		t.Fatalf("requeue: %v", err)
+	}
+	requeued, err := queue.PopEmail(ctx, consumer, time.Second)
+	if err != nil || requeued == nil {
+		t.Fatalf("PopEmail after requeue = %v, %v", requeued, err)
+	}
+	if !handled(loggedStatus, loggedAttempt, requeued.Attempt) {
+		t.Fatal("requeued task must not resend a recipient whose retry is already scheduled")
+	}
+
+	scheduled, err := queue.Client.ZRange(ctx, queue.ScheduledKey, 0, -1).Result()
+	if err != nil || len(scheduled) != 1 {
+		t.Fatalf("scheduled retries = %v, %v", scheduled, err)
+	}
+	data, err := queue.Client.HGet(ctx, queue.ScheduledTasksKey, scheduled[0]).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var retry queue.EmailTask
+	if err := json.Unmarshal([]byte(data), &retry); err != nil {
+		t.Fatal(err)
+	}
+	if retry.Attempt != attempt || len(retry.Recipients) != 1 || retry.Recipients[0].ID != recipient.ID {
+		t.Fatalf("unexpected retry task: %+v", retry)
+	}
+	if handled(loggedStatus, loggedAttempt, retry.Attempt) {
+		t.Fatal("the scheduled retry itself must still be sent")
+	}
+}
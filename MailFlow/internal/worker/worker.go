@@ -1,19 +1,30 @@
 package worker
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
 	"net/smtp"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/mailflow/smtp-loadbalancer/internal/auth"
+	"github.com/mailflow/smtp-loadbalancer/internal/bounce"
+	"github.com/mailflow/smtp-loadbalancer/internal/config"
 	"github.com/mailflow/smtp-loadbalancer/internal/database"
+	"github.com/mailflow/smtp-loadbalancer/internal/deadletter"
+	"github.com/mailflow/smtp-loadbalancer/internal/dkim"
+	"github.com/mailflow/smtp-loadbalancer/internal/events"
 	"github.com/mailflow/smtp-loadbalancer/internal/loadbalancer"
 	"github.com/mailflow/smtp-loadbalancer/internal/models"
 	"github.com/mailflow/smtp-loadbalancer/internal/queue"
 	"github.com/mailflow/smtp-loadbalancer/internal/stats"
+	"github.com/mailflow/smtp-loadbalancer/internal/suppression"
+	"github.com/mailflow/smtp-loadbalancer/internal/unsubscribe"
 	"gopkg.in/gomail.v2"
 	"gorm.io/gorm"
 )
@@ -35,14 +46,45 @@ func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
 	return nil, nil
 }
 
-func Start(ctx context.Context, workerCount int) {
+var policy config.WorkerConfig
+
+func Start(ctx context.Context, cfg *config.WorkerConfig) {
+	policy = *cfg
+	workerCount := cfg.Count
+	hostname, _ := os.Hostname()
+	consumers := make([]string, 0, workerCount)
+
 	for i := 0; i < workerCount; i++ {
-		go worker(ctx, i)
+		consumer := fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), i)
+		if err := queue.RegisterConsumer(ctx, consumer); err != nil {
+			log.Printf("注册Worker %d 失败: %v", i, err)
+		}
+		consumers = append(consumers, consumer)
+		go worker(ctx, i, consumer)
 	}
+	go heartbeat(ctx, consumers)
 	log.Printf("启动了 %d 个邮件发送Worker", workerCount)
 }
 
-func worker(ctx context.Context, id int) {
+func heartbeat(ctx context.Context, consumers []string) {
+	ticker := time.NewTicker(queue.HeartbeatTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, consumer := range consumers {
+				if err := queue.Heartbeat(ctx, consumer); err != nil {
+					log.Printf("Worker心跳上报失败 [%s]: %v", consumer, err)
+				}
+			}
+		}
+	}
+}
+
+func worker(ctx context.Context, id int, consumer string) {
 	log.Printf("Worker %d 已启动", id)
 	
 	for {
@@ -51,9 +93,11 @@ func worker(ctx context.Context, id int) {
 			log.Printf("Worker %d 正在关闭", id)
 			return
 		default:
-			task, err := queue.PopEmail(ctx, 5*time.Second)
+			task, err := queue.PopEmail(ctx, consumer, 5*time.Second)
 			if err != nil {
-				log.Printf("Worker %d 获取任务失败: %v", id, err)
+				if ctx.Err() == nil {
+					log.Printf("Worker %d 获取任务失败: %v", id, err)
+				}
 				continue
 			}
 
@@ -64,67 +108,185 @@ func worker(ctx context.Context, id int) {
 			if err := processEmail(ctx, task); err != nil {
 				log.Printf("Worker %d 处理任务失败: %v", id, err)
 			}
+
+			if err := queue.AckEmail(context.Background(), consumer, task); err != nil {
+				log.Printf("Worker %d 确认任务失败: %v", id, err)
+			}
 		}
 	}
 }
 
 func processEmail(ctx context.Context, task *queue.EmailTask) error {
-	const maxRetries = 3
-	
-	for _, recipient := range task.To {
-		var lastErr error
-		var successSMTP *models.SMTPConfig
-		
-		for attempt := 0; attempt < maxRetries; attempt++ {
-			smtpConfig, err := loadbalancer.SelectSMTP(ctx)
-			if err != nil {
-				lastErr = err
-				log.Printf("尝试 %d/%d: 无法获取SMTP服务器 [%s]: %v", attempt+1, maxRetries, recipient, err)
-				time.Sleep(time.Duration(attempt+1) * time.Second)
-				continue
-			}
+	task.AssignIDs()
 
-			if err := sendEmail(smtpConfig, recipient, task); err != nil {
-				lastErr = err
-				log.Printf("尝试 %d/%d: SMTP[%s] 发送失败 [%s]: %v", attempt+1, maxRetries, smtpConfig.Name, recipient, err)
-				time.Sleep(time.Duration(attempt+1) * time.Second)
-				continue
-			}
-			
-			successSMTP = smtpConfig
-			break
+	blobs, blobErr := loadAttachments(ctx, task)
+
+	for _, recipient := range task.Recipients {
+		if alreadyHandled(task, recipient) {
+			continue
 		}
+		if entry, err := suppression.Find(task.APIKeyID, recipient.Email, task.Category == queue.CategoryMarketing); err == nil && entry != nil {
+			skipSuppressed(ctx, task, recipient, entry)
+			continue
+		}
+
+		attempt := task.Attempt + 1
+		updateLog(task, recipient, "sending", 0, "")
 
-		if successSMTP != nil {
-			logSuccess(task, successSMTP.ID, recipient)
+		var smtpConfig *models.SMTPConfig
+		err := blobErr
+		if err == nil {
+			smtpConfig, err = loadbalancer.SelectSMTP(ctx, task.FromDomain())
+		}
+		if err == nil {
+			err = sendEmail(smtpConfig, recipient, task, blobs)
+		}
+
+		if err == nil {
+			updateLog(task, recipient, "success", smtpConfig.ID, "")
 			stats.IncrementSent(ctx, task.APIKeyID)
-			loadbalancer.IncrementSMTPCount(ctx, successSMTP.ID)
-			auth.ConsumeQuota(ctx, task.APIKeyID)
+			loadbalancer.IncrementSMTPCount(ctx, smtpConfig.ID)
+			if task.QuotaReservedAt == nil {
+				auth.ConsumeQuota(ctx, task.APIKeyID)
+			}
 			database.DB.Model(&models.APIKey{}).Where("id = ?", task.APIKeyID).UpdateColumn("total_used", gorm.Expr("total_used + ?", 1))
-			log.Printf("邮件发送成功 [SMTP: %s] [收件人: %s]", successSMTP.Name, recipient)
+			publishEvent(ctx, events.EmailSent, task, recipient, attempt, "")
+			log.Printf("邮件发送成功 [SMTP: %s] [收件人: %s]", smtpConfig.Name, recipient.Email)
+			continue
+		}
+
+		var smtpID uint
+		attemptErr := queue.AttemptError{Attempt: attempt, Error: err.Error(), At: time.Now()}
+		if smtpConfig != nil {
+			smtpID = smtpConfig.ID
+			attemptErr.SMTP = smtpConfig.Name
+			log.Printf("尝试 %d/%d: SMTP[%s] 发送失败 [%s]: %v", attempt, policy.MaxAttempts, smtpConfig.Name, recipient.Email, err)
+		} else {
+			log.Printf("尝试 %d/%d: 无法获取SMTP服务器 [%s]: %v", attempt, policy.MaxAttempts, recipient.Email, err)
+		}
+		history := append(append([]queue.AttemptError{}, task.Errors...), attemptErr)
+
+		permanent := isPermanentError(err)
+		if !permanent {
+			delay := retryDelay(attempt)
+			if attempt < policy.MaxAttempts && time.Since(task.CreatedAt)+delay < policy.MaxAge {
+				retryErr := scheduleRetry(ctx, task, recipient, attempt, history, delay)
+				if retryErr == nil {
+					updateLog(task, recipient, "retrying", smtpID, err.Error())
+					log.Printf("邮件将在 %s 后重试 [%s]", delay.Round(time.Second), recipient.Email)
+					continue
+				}
+				log.Printf("安排重试失败 [%s]: %v", recipient.Email, retryErr)
+			}
+		}
+
+		var errorMsg string
+		if permanent {
+			errorMsg = fmt.Sprintf("永久性错误，不再重试: %v", err)
+		} else {
+			errorMsg = fmt.Sprintf("重试%d次后失败: %v", attempt, err)
+		}
+		updateLog(task, recipient, "failed", smtpID, errorMsg)
+		if isBounce(err) {
+			publishEvent(ctx, events.EmailBounced, task, recipient, attempt, err.Error())
+			suppression.RecordBounce(recipient.Email, suppression.SourceSMTP, err.Error())
 		} else {
-			errorMsg := fmt.Sprintf("重试%d次后失败: %v", maxRetries, lastErr)
-			logFailure(task, 0, errorMsg)
-			stats.IncrementFailed(ctx, task.APIKeyID)
-			log.Printf("邮件发送彻底失败 [%s]: %s", recipient, errorMsg)
+			publishEvent(ctx, events.EmailFailed, task, recipient, attempt, err.Error())
+		}
+		stats.IncrementFailed(ctx, task.APIKeyID)
+		if task.QuotaReservedAt != nil {
+			auth.RefundQuota(ctx, task.APIKeyID, 1, *task.QuotaReservedAt)
+		}
+		if err := deadletter.Add(task, recipient, history); err != nil {
+			log.Printf("写入死信队列失败 [%s]: %v", recipient.Email, err)
 		}
+		log.Printf("邮件发送彻底失败 [%s]: %s", recipient.Email, errorMsg)
 	}
 
 	return nil
 }
 
-func sendEmail(config *models.SMTPConfig, to string, task *queue.EmailTask) error {
+func skipSuppressed(ctx context.Context, task *queue.EmailTask, recipient queue.Recipient, entry *models.Suppression) {
+	errorMsg := fmt.Sprintf("收件人在抑制列表中 [%s]", entry.Reason)
+	updateLog(task, recipient, "suppressed", 0, errorMsg)
+	if task.QuotaReservedAt != nil {
+		auth.RefundQuota(ctx, task.APIKeyID, 1, *task.QuotaReservedAt)
+	}
+	publishEvent(ctx, events.EmailSuppressed, task, recipient, task.Attempt+1, errorMsg)
+	log.Printf("跳过抑制列表中的收件人 [%s]: %s", recipient.Email, entry.Reason)
+}
+
+func publishEvent(ctx context.Context, eventType string, task *queue.EmailTask, recipient queue.Recipient, attempt int, errorMsg string) {
+	data := map[string]interface{}{
+		"message_id":   task.MessageID,
+		"recipient_id": recipient.ID,
+		"to":           recipient.Email,
+		"subject":      task.Subject,
+		"attempt":      attempt,
+	}
+	if errorMsg != "" {
+		data["error"] = errorMsg
+	}
+	if err := events.Publish(ctx, eventType, task.APIKeyID, data); err != nil {
+		log.Printf("发布事件失败 [%s] [%s]: %v", eventType, recipient.Email, err)
+	}
+}
+
+func loadAttachments(ctx context.Context, task *queue.EmailTask) (map[string][]byte, error) {
+	blobs := make(map[string][]byte, len(task.Attachments))
+	for _, a := range task.Attachments {
+		data, err := queue.LoadBlob(ctx, a.BlobKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errAttachmentUnavailable, err)
+		}
+		blobs[a.BlobKey] = data
+	}
+	return blobs, nil
+}
+
+func sendEmail(config *models.SMTPConfig, recipient queue.Recipient, task *queue.EmailTask, blobs map[string][]byte) error {
+	to := recipient.Email
 	m := gomail.NewMessage()
 	
-	if config.FromName != "" {
-		m.SetHeader("From", m.FormatAddress(config.FromEmail, config.FromName))
+	fromEmail, fromName := config.FromEmail, config.FromName
+	if task.FromEmail != "" {
+		fromEmail, fromName = task.FromEmail, task.FromName
+	}
+
+	if fromName != "" {
+		m.SetHeader("From", m.FormatAddress(fromEmail, fromName))
 	} else {
-		m.SetHeader("From", config.FromEmail)
+		m.SetHeader("From", fromEmail)
 	}
 	
-	m.SetHeader("To", to)
+	if task.SharedHeaders() {
+		if len(task.To) > 0 {
+			m.SetHeader("To", task.To...)
+		}
+		if len(task.Cc) > 0 {
+			m.SetHeader("Cc", task.Cc...)
+		}
+	} else {
+		m.SetHeader("To", to)
+	}
 	m.SetHeader("Subject", task.Subject)
 
+	if task.ReplyTo != "" {
+		m.SetHeader("Reply-To", task.ReplyTo)
+	}
+	for name, value := range task.Headers {
+		m.SetHeader(name, value)
+	}
+	if task.Category == queue.CategoryMarketing {
+		listUnsubscribe := "<" + unsubscribe.URL(task.APIKeyID, recipient.ID, to) + ">"
+		if value := task.Headers["List-Unsubscribe"]; value != "" {
+			listUnsubscribe += ", " + value
+		}
+		m.SetHeader("List-Unsubscribe", listUnsubscribe)
+		m.SetHeader("List-Unsubscribe-Post", unsubscribe.OneClickBody)
+	}
+	m.SetHeader("Message-ID", bounce.MessageID(recipient.ID, fromEmail))
+
 	if task.HTML != "" {
 		m.SetBody("text/html", task.HTML)
 		if task.Text != "" {
@@ -134,6 +296,36 @@ func sendEmail(config *models.SMTPConfig, to string, task *queue.EmailTask) erro
 		m.SetBody("text/plain", task.Text)
 	}
 
+	for _, a := range task.Attachments {
+		data := blobs[a.BlobKey]
+		settings := []gomail.FileSetting{
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			}),
+		}
+
+		header := map[string][]string{}
+		if a.ContentType != "" {
+			header["Content-Type"] = []string{a.ContentType + `; name="` + filepath.Base(a.Filename) + `"`}
+		}
+		if a.ContentID != "" {
+			header["Content-ID"] = []string{"<" + a.ContentID + ">"}
+			settings = append(settings, gomail.SetHeader(header))
+			m.Embed(a.Filename, settings...)
+			continue
+		}
+		if len(header) > 0 {
+			settings = append(settings, gomail.SetHeader(header))
+		}
+		m.Attach(a.Filename, settings...)
+	}
+
+	message, err := signMessage(m, fromEmail)
+	if err != nil {
+		return err
+	}
+
 	d := gomail.NewDialer(config.Host, config.Port, config.Username, config.Password)
 
 	encryption := config.Encryption
@@ -172,37 +364,89 @@ func sendEmail(config *models.SMTPConfig, to string, task *queue.EmailTask) erro
 		}
 	}
 
-	if err := d.DialAndSend(m); err != nil {
+	s, err := d.Dial()
+	if err != nil {
+		return fmt.Errorf("SMTP发送失败: %w", err)
+	}
+	defer s.Close()
+
+	envelopeFrom := fromEmail
+	if config.ReturnPath != "" {
+		envelopeFrom = bounce.ReturnPath(config.ReturnPath, recipient.ID)
+	}
+
+	if err := s.Send(envelopeFrom, []string{to}, message); err != nil {
 		return fmt.Errorf("SMTP发送失败: %w", err)
 	}
 
 	return nil
 }
 
-func logSuccess(task *queue.EmailTask, smtpID uint, recipient string) {
+func signMessage(m *gomail.Message, fromEmail string) (io.WriterTo, error) {
+	key, err := dkim.Find(fromEmail)
+	if err != nil {
+		return nil, fmt.Errorf("查询DKIM密钥失败: %w", err)
+	}
+	if key == nil {
+		return m, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("生成邮件失败: %w", err)
+	}
+
+	signed, err := dkim.Sign(buf.Bytes(), key)
+	if err != nil {
+		return nil, fmt.Errorf("DKIM签名失败 [%s/%s]: %w", key.Domain, key.Selector, err)
+	}
+	return bytes.NewReader(signed), nil
+}
+
+func alreadyHandled(task *queue.EmailTask, recipient queue.Recipient) bool {
+	var sendLog models.SendLog
+	err := database.DB.Select("status", "attempt").Where("recipient_id = ?", recipient.ID).Limit(1).Find(&sendLog).Error
+	if err != nil {
+		return false
+	}
+	return handled(sendLog.Status, sendLog.Attempt, task.Attempt)
+}
+
+func handled(status string, loggedAttempt, taskAttempt int) bool {
+	switch status {
+	case "success", "bounced", "failed", "suppressed", "cancelled":
+		return true
+	case "retrying":
+		return loggedAttempt > taskAttempt
+	}
+	return false
+}
+
+func updateLog(task *queue.EmailTask, recipient queue.Recipient, status string, smtpID uint, errorMsg string) {
+	result := database.DB.Model(&models.SendLog{}).
+		Where("recipient_id = ?", recipient.ID).
+		Updates(map[string]interface{}{
+			"status":         status,
+			"error_msg":      errorMsg,
+			"smtp_config_id": smtpID,
+			"attempt":        task.Attempt + 1,
+			"updated_at":     time.Now(),
+		})
+	if result.Error == nil && result.RowsAffected > 0 {
+		return
+	}
+
 	log := models.SendLog{
+		MessageID:    task.MessageID,
+		RecipientID:  recipient.ID,
 		APIKeyID:     task.APIKeyID,
-		To:           recipient,
+		To:           recipient.Email,
 		Subject:      task.Subject,
-		Status:       "success",
+		Status:       status,
+		ErrorMsg:     errorMsg,
 		SMTPConfigID: smtpID,
+		Attempt:      task.Attempt + 1,
 		CreatedAt:    time.Now(),
 	}
 	database.DB.Create(&log)
 }
-
-func logFailure(task *queue.EmailTask, smtpID uint, errorMsg string) {
-	for _, recipient := range task.To {
-		log := models.SendLog{
-			APIKeyID:     task.APIKeyID,
-			To:           recipient,
-			Subject:      task.Subject,
-			Status:       "failed",
-			ErrorMsg:     errorMsg,
-			SMTPConfigID: smtpID,
-			CreatedAt:    time.Now(),
-		}
-		database.DB.Create(&log)
-	}
-}
-
@@ -129,11 +129,12 @@ function mailflow_TestLink($params)
 function mailflow_ClientArea($params)
 {
     return [
-        'info'  => ['name' => 'API Key信息'],
-        'docs'  => ['name' => '使用文档'],
-        'quota' => ['name' => '配额详情'],
-        'stats' => ['name' => '使用统计'],
-        'logs'  => ['name' => '发送日志'],
+        'info'     => ['name' => 'API Key信息'],
+        'docs'     => ['name' => '使用文档'],
+        'quota'    => ['name' => '配额详情'],
+        'stats'    => ['name' => '使用统计'],
+        'logs'     => ['name' => '发送日志'],
+        'security' => ['name' => '访问限制'],
     ];
 }
 
@@ -190,6 +191,65 @@ function mailflow_ClientAreaOutput($params, $key)
             echo json_encode($res ?? ['code' => 500, 'msg' => '获取日志失败']);
             exit;
         }
+
+        if ($action === 'getrestrictions' || $action === 'saverestrictions') {
+            if (!$apiKeyID) {
+                header('Content-Type: application/json');
+                echo json_encode(['code' => 404, 'msg' => 'API Key不存在']);
+                exit;
+            }
+
+            if ($action === 'saverestrictions') {
+                $data = [
+                    'allowed_ips'     => $_GET['allowed_ips'] ?? '',
+                    'allowed_origins' => $_GET['allowed_origins'] ?? '',
+                ];
+                $res = mailflow_Curl($params, "/admin/api/keys/{$apiKeyID}", $data, 'PUT', true);
+            } else {
+                $res = mailflow_Curl($params, '/admin/api/keys/lookup', ['key' => $apiKey], 'POST', true);
+            }
+
+            header('Content-Type: application/json');
+            if (!isset($res['id'])) {
+                echo json_encode(['code' => 500, 'msg' => $res['error'] ?? '操作失败']);
+                exit;
+            }
+            echo json_encode([
+                'code'            => 200,
+                'allowed_ips'     => $res['allowed_ips'] ?? '',
+                'allowed_origins' => $res['allowed_origins'] ?? '',
+            ]);
+            exit;
+        }
+
+        if ($action === 'rotatekey') {
+            if (!$apiKeyID) {
+                header('Content-Type: application/json');
+                echo json_encode(['code' => 404, 'msg' => 'API Key不存在']);
+                exit;
+            }
+
+            $res = mailflow_Curl($params, "/admin/api/keys/{$apiKeyID}/rotate", (object)[], 'POST', true);
+            if (!isset($res['key'])) {
+                header('Content-Type: application/json');
+                echo json_encode(['code' => 500, 'msg' => $res['error'] ?? '轮换API Key失败']);
+                exit;
+            }
+
+            try {
+                Db::name('host')->where('id', $params['hostid'])->update(['username' => $res['key']]);
+            } catch (\Exception $e) {
+                mailflow_debug('轮换后同步API Key失败', ['error' => $e->getMessage()]);
+            }
+
+            header('Content-Type: application/json');
+            echo json_encode([
+                'code' => 200,
+                'key' => $res['key'],
+                'previous_key_expires_at' => $res['previous_key_expires_at'] ?? null,
+            ]);
+            exit;
+        }
     }
 
     if ($key == 'info') {
@@ -234,6 +294,13 @@ function mailflow_ClientAreaOutput($params, $key)
             'vars'     => [],
         ];
     }
+
+    if ($key == 'security') {
+        return [
+            'template' => 'templates/security.html',
+            'vars'     => [],
+        ];
+    }
 }
 
 // 允许客户端调用的函数列表
@@ -477,14 +544,10 @@ function mailflow_GetAPIKeyID($params)
     }
 
     $apiKey = $params['username'];
-    $res = mailflow_Curl($params, '/admin/api/keys', [], 'GET', true);
+    $res = mailflow_Curl($params, '/admin/api/keys/lookup', ['key' => $apiKey], 'POST', true);
 
-    if (is_array($res)) {
-        foreach ($res as $keyInfo) {
-            if ($keyInfo['key'] === $apiKey) {
-                return $keyInfo['id'];
-            }
-        }
+    if (isset($res['id'])) {
+        return $res['id'];
     }
 
     return null;